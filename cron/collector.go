@@ -25,7 +25,8 @@ func Collect() {
 		return
 	}
 
-	if len(g.Config().Transfer.Addrs) == 0 {
+	// With transfer.srv the addresses may only be known once it resolved.
+	if len(g.TransferAddrs()) == 0 && g.Config().Transfer.Srv == "" {
 		return
 	}
 
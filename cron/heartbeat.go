@@ -0,0 +1,44 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/domeos/agent/funcs"
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+)
+
+// SendAliveHeartbeat pushes agent_alive to transfer every
+// transfer.heartbeatInterval seconds until stop is closed, so dead agents can
+// be alerted on independently of the collectors. It returns right away if no
+// interval is configured.
+func SendAliveHeartbeat(stop <-chan struct{}) {
+	sec := g.Config().Transfer.HeartbeatInterval
+	if sec <= 0 {
+		return
+	}
+	sendAliveHeartbeat(time.Duration(sec)*time.Second, stop)
+}
+
+func sendAliveHeartbeat(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		hostname, err := g.Hostname()
+		if err != nil {
+			continue
+		}
+		mv := funcs.GaugeValue("agent_alive", 1)
+		mv.Endpoint = hostname
+		mv.Step = int64(interval / time.Second)
+		mv.Timestamp = time.Now().Unix()
+		g.SendToTransfer([]*model.MetricValue{mv})
+	}
+}
@@ -0,0 +1,52 @@
+package cron
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+)
+
+type chanSink chan []*model.MetricValue
+
+func (s chanSink) Send(metrics []*model.MetricValue) { s <- metrics }
+
+func TestSendAliveHeartbeat(t *testing.T) {
+	f, err := ioutil.TempFile("", "agent-cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"hostname": "test-host", "transfer": {"enabled": false, "addrs": []}}`)
+	f.Close()
+	g.ParseConfig(f.Name())
+
+	sink := make(chanSink, 10)
+	g.AddSink(sink)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		sendAliveHeartbeat(10*time.Millisecond, stop)
+		close(done)
+	}()
+
+	select {
+	case metrics := <-sink:
+		if len(metrics) != 1 || metrics[0].Metric != "agent_alive" || metrics[0].Endpoint != "test-host" {
+			t.Errorf("unexpected heartbeat %v", metrics)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat pushed")
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat did not stop")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/toolkits/file"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -27,12 +29,85 @@ type TransferConfig struct {
 	Addrs    []string `json:"addrs"`
 	Interval int      `json:"interval"`
 	Timeout  int      `json:"timeout"`
+	// HeartbeatInterval is the interval in seconds agent_alive is pushed
+	// at; 0 disables it.
+	HeartbeatInterval int `json:"heartbeatInterval"`
+	// IdleTimeout is the time in seconds after which an unused connection
+	// to a transfer is closed and dialed again on the next send; 0 keeps
+	// it open.
+	IdleTimeout int `json:"idleTimeout"`
+	// Srv is a DNS SRV record, e.g. _transfer._tcp.falcon.example.com,
+	// whose targets replace Addrs. It is resolved again every
+	// SrvInterval seconds, 60 by default; Addrs are used until it
+	// resolves.
+	Srv         string `json:"srv"`
+	SrvInterval int    `json:"srvInterval"`
 }
 
 type HttpConfig struct {
-	Enabled  bool   `json:"enabled"`
-	Listen   string `json:"listen"`
-	Backdoor bool   `json:"backdoor"`
+	Enabled      bool   `json:"enabled"`
+	Listen       string `json:"listen"`
+	Backdoor     bool   `json:"backdoor"`
+	StrictPush   bool   `json:"strictPush"`
+	MaxPushBytes int64  `json:"maxPushBytes"`
+	MaxPushDepth int    `json:"maxPushDepth"`
+	// MaxDecompressedBytes bounds gzip encoded push bodies after
+	// decompression; it defaults to the limit of plain bodies.
+	MaxDecompressedBytes int64 `json:"maxDecompressedBytes"`
+	DefaultStep          int64 `json:"defaultStep"`
+	// PushQueueSize is the number of pushed batches buffered for
+	// transfer, so that clients do not wait for it; 0 sends them
+	// synchronously. The queue is drained for up to PushDrainTimeout
+	// seconds, 10 by default, on shutdown.
+	PushQueueSize    int `json:"pushQueueSize"`
+	PushDrainTimeout int `json:"pushDrainTimeout"`
+	// PushSchema is the path of a JSON Schema /v1/push bodies must
+	// satisfy, e.g. to require tags; see http/schema.go for the keywords
+	// supported.
+	PushSchema string `json:"pushSchema"`
+	// RejectEmptyPush answers empty push arrays with 400 instead of
+	// accepting nothing.
+	RejectEmptyPush bool `json:"rejectEmptyPush"`
+	// DefaultTags are added to every pushed metric; tags sent by the
+	// client win on conflict.
+	DefaultTags map[string]string `json:"defaultTags"`
+	// ValidationMode is "open" to drop invalid pushed metrics and accept
+	// the rest, or "closed" to reject the whole batch. Defaults to open.
+	ValidationMode string `json:"validationMode"`
+	// MaxTimestampSkew is the number of seconds a pushed timestamp may be
+	// away from the agent's clock; 0 accepts any. TimestampSkewAction is
+	// "reject" (default) to treat such metrics as invalid, or "clamp" to
+	// move their timestamp to the nearest accepted one.
+	MaxTimestampSkew    int64  `json:"maxTimestampSkew"`
+	TimestampSkewAction string `json:"timestampSkewAction"`
+	// EndpointSource selects what a blank pushed endpoint is set to:
+	// "hostname" (default), "remote-addr" or "header", the latter reading
+	// EndpointHeader, X-Forwarded-For by default.
+	EndpointSource string `json:"endpointSource"`
+	EndpointHeader string `json:"endpointHeader"`
+	// EndpointStripSuffixes are removed from the end of pushed endpoints,
+	// e.g. ".internal.example.com" to turn FQDNs into short names. The
+	// EndpointRegexp matches are then replaced with EndpointReplacement.
+	EndpointStripSuffixes []string `json:"endpointStripSuffixes"`
+	EndpointRegexp        string   `json:"endpointRegexp"`
+	EndpointReplacement   string   `json:"endpointReplacement"`
+	// SampleRules forward only a fraction of the pushed series whose
+	// metric name matches; the first matching rule applies.
+	SampleRules []SampleRule `json:"sampleRules"`
+}
+
+type SampleRule struct {
+	// Metric is a regular expression matched against the metric name.
+	Metric string `json:"metric"`
+	// Rate is the fraction of the matching series forwarded, from 0 to 1.
+	Rate float64 `json:"rate"`
+}
+
+type KafkaConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Brokers    []string `json:"brokers"`
+	Topic      string   `json:"topic"`
+	BufferSize int      `json:"bufferSize"`
 }
 
 type CollectorConfig struct {
@@ -49,6 +124,7 @@ type GlobalConfig struct {
 	Heartbeat     *HeartbeatConfig `json:"heartbeat"`
 	Transfer      *TransferConfig  `json:"transfer"`
 	Http          *HttpConfig      `json:"http"`
+	Kafka         *KafkaConfig     `json:"kafka"`
 	Collector     *CollectorConfig `json:"collector"`
 	IgnoreMetrics map[string]bool  `json:"ignore"`
 }
@@ -114,6 +190,8 @@ func ParseConfig(cfg string) {
 		log.Fatalln("parse config file:", cfg, "fail:", err)
 	}
 
+	applyEnvOverrides(&c)
+
 	if c.Hostname == "" {
 		hostname, err := os.Hostname()
 		if err != nil {
@@ -131,3 +209,51 @@ func ParseConfig(cfg string) {
 	//bytes, _ := json.Marshal(c)
 	//log.Fatalln("use -c to specify configuration file", string(bytes))
 }
+
+// applyEnvOverrides merges environment variables over the file configuration
+// for container deployments. Precedence is env > config file > defaults
+// applied after loading (e.g. os.Hostname() for an empty hostname).
+//
+//	AGENT_HOSTNAME        hostname
+//	AGENT_IP              ip
+//	AGENT_APISERVER       apiserver
+//	AGENT_TRANSFER_ADDRS  transfer.addrs, comma separated
+//	AGENT_MAX_PUSH_BYTES  http.maxPushBytes
+//	AGENT_MAX_PUSH_DEPTH  http.maxPushDepth
+func applyEnvOverrides(c *GlobalConfig) {
+	if v := os.Getenv("AGENT_HOSTNAME"); v != "" {
+		c.Hostname = v
+	}
+	if v := os.Getenv("AGENT_IP"); v != "" {
+		c.IP = v
+	}
+	if v := os.Getenv("AGENT_APISERVER"); v != "" {
+		c.Apiserver = v
+	}
+	if v := os.Getenv("AGENT_TRANSFER_ADDRS"); v != "" {
+		if c.Transfer == nil {
+			c.Transfer = &TransferConfig{}
+		}
+		c.Transfer.Addrs = strings.Split(v, ",")
+	}
+	if v := os.Getenv("AGENT_MAX_PUSH_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatalln("parse AGENT_MAX_PUSH_BYTES:", v, "fail:", err)
+		}
+		if c.Http == nil {
+			c.Http = &HttpConfig{}
+		}
+		c.Http.MaxPushBytes = n
+	}
+	if v := os.Getenv("AGENT_MAX_PUSH_DEPTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalln("parse AGENT_MAX_PUSH_DEPTH:", v, "fail:", err)
+		}
+		if c.Http == nil {
+			c.Http = &HttpConfig{}
+		}
+		c.Http.MaxPushDepth = n
+	}
+}
@@ -0,0 +1,31 @@
+package g
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestEnvOverridesConfigFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "agent-cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"hostname": "file-host", "transfer": {"addrs": ["127.0.0.1:8433"]}}`)
+	f.Close()
+
+	os.Setenv("AGENT_HOSTNAME", "env-host")
+	os.Setenv("AGENT_TRANSFER_ADDRS", "10.0.0.1:8433,10.0.0.2:8433")
+	defer os.Unsetenv("AGENT_HOSTNAME")
+	defer os.Unsetenv("AGENT_TRANSFER_ADDRS")
+
+	ParseConfig(f.Name())
+
+	if h := Config().Hostname; h != "env-host" {
+		t.Errorf("expected hostname from env, got %q", h)
+	}
+	if addrs := Config().Transfer.Addrs; len(addrs) != 2 || addrs[1] != "10.0.0.2:8433" {
+		t.Errorf("expected transfer addrs from env, got %v", addrs)
+	}
+}
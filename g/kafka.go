@@ -0,0 +1,90 @@
+package g
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/open-falcon/common/model"
+)
+
+const defaultKafkaBufferSize = 1024
+
+// KafkaProducer produces a single message to a Kafka topic.
+type KafkaProducer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// NewKafkaProducer creates the producer used by the Kafka sink. No Kafka
+// client is linked into the agent by default; builds that enable the sink
+// set this from an init function, wrapping a maintained client such as
+// sarama or franz-go.
+var NewKafkaProducer func(brokers []string) (KafkaProducer, error)
+
+type kafkaMessage struct {
+	key, value []byte
+}
+
+// KafkaSink publishes each batch as JSON, one message per endpoint keyed by
+// the endpoint. Messages are buffered so Send never blocks the push path;
+// batches arriving while the buffer is full are dropped.
+type KafkaSink struct {
+	producer KafkaProducer
+	topic    string
+	queue    chan kafkaMessage
+}
+
+func NewKafkaSink(producer KafkaProducer, topic string, bufferSize int) *KafkaSink {
+	if bufferSize <= 0 {
+		bufferSize = defaultKafkaBufferSize
+	}
+	s := &KafkaSink{
+		producer: producer,
+		topic:    topic,
+		queue:    make(chan kafkaMessage, bufferSize),
+	}
+	go s.run()
+	return s
+}
+
+func (this *KafkaSink) Send(metrics []*model.MetricValue) {
+	byEndpoint := map[string][]*model.MetricValue{}
+	for _, m := range metrics {
+		byEndpoint[m.Endpoint] = append(byEndpoint[m.Endpoint], m)
+	}
+
+	for endpoint, batch := range byEndpoint {
+		bs, err := json.Marshal(batch)
+		if err != nil {
+			log.Println("marshal metrics for kafka fail:", err)
+			continue
+		}
+		select {
+		case this.queue <- kafkaMessage{key: []byte(endpoint), value: bs}:
+		default:
+			log.Printf("[WARN] kafka buffer full, drop %d metrics of %s", len(batch), endpoint)
+		}
+	}
+}
+
+func (this *KafkaSink) run() {
+	for m := range this.queue {
+		if err := this.producer.Produce(this.topic, m.key, m.value); err != nil {
+			log.Println("produce to kafka topic", this.topic, "fail:", err)
+		}
+	}
+}
+
+func initKafkaSink() {
+	cfg := Config().Kafka
+	if NewKafkaProducer == nil {
+		log.Fatalln("kafka is enabled but no kafka producer is linked in, see g.NewKafkaProducer")
+	}
+	if cfg.Topic == "" {
+		log.Fatalln("kafka is enabled but kafka.topic is empty")
+	}
+	producer, err := NewKafkaProducer(cfg.Brokers)
+	if err != nil {
+		log.Fatalln("create kafka producer fail:", err)
+	}
+	AddSink(NewKafkaSink(producer, cfg.Topic, cfg.BufferSize))
+}
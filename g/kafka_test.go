@@ -0,0 +1,52 @@
+package g
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/open-falcon/common/model"
+)
+
+type producedMessage struct {
+	topic      string
+	key, value []byte
+}
+
+type mockProducer chan producedMessage
+
+func (p mockProducer) Produce(topic string, key, value []byte) error {
+	p <- producedMessage{topic, key, value}
+	return nil
+}
+
+func TestKafkaSinkProducesPerEndpoint(t *testing.T) {
+	p := make(mockProducer, 10)
+	s := NewKafkaSink(p, "metrics", 10)
+
+	s.Send([]*model.MetricValue{
+		{Endpoint: "host-a", Metric: "cpu.idle", Value: 1},
+		{Endpoint: "host-a", Metric: "mem.used", Value: 2},
+		{Endpoint: "host-b", Metric: "cpu.idle", Value: 3},
+	})
+
+	counts := map[string]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-p:
+			if m.topic != "metrics" {
+				t.Errorf("unexpected topic %s", m.topic)
+			}
+			var batch []*model.MetricValue
+			if err := json.Unmarshal(m.value, &batch); err != nil {
+				t.Fatalf("message is not a JSON batch: %s", err)
+			}
+			counts[string(m.key)] = len(batch)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message produced")
+		}
+	}
+	if counts["host-a"] != 2 || counts["host-b"] != 1 {
+		t.Errorf("unexpected batches per endpoint %v", counts)
+	}
+}
@@ -9,11 +9,15 @@ import (
 	"time"
 )
 
+// SingleConnRpcClient keeps one connection to RpcServer and reuses it for
+// every call until it fails or, with an IdleTimeout, was not used for longer.
 type SingleConnRpcClient struct {
 	sync.Mutex
-	rpcClient *rpc.Client
-	RpcServer string
-	Timeout   time.Duration
+	rpcClient   *rpc.Client
+	RpcServer   string
+	Timeout     time.Duration
+	IdleTimeout time.Duration
+	lastUsed    time.Time
 }
 
 func (this *SingleConnRpcClient) close() {
@@ -55,6 +59,11 @@ func (this *SingleConnRpcClient) Call(method string, args interface{}, reply int
 	this.Lock()
 	defer this.Unlock()
 
+	if this.IdleTimeout > 0 && time.Since(this.lastUsed) > this.IdleTimeout {
+		this.close()
+	}
+	this.lastUsed = time.Now()
+
 	err := this.serverConn()
 	if err != nil {
 		return err
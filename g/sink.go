@@ -0,0 +1,36 @@
+package g
+
+import (
+	"sync"
+
+	"github.com/open-falcon/common/model"
+)
+
+// Sink receives every batch of metrics sent to transfer, e.g. to publish it
+// to a streaming pipeline as well.
+type Sink interface {
+	Send(metrics []*model.MetricValue)
+}
+
+var (
+	sinks     []Sink
+	sinksLock = new(sync.RWMutex)
+)
+
+func AddSink(s Sink) {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+	sinks = append(sinks, s)
+}
+
+func Sinks() []Sink {
+	sinksLock.RLock()
+	defer sinksLock.RUnlock()
+	return sinks
+}
+
+func InitSinks() {
+	if Config().Kafka != nil && Config().Kafka.Enabled {
+		initKafkaSink()
+	}
+}
@@ -0,0 +1,72 @@
+package g
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultSrvInterval = 60
+
+// lookupSRV resolves the transfer.srv record; tests replace it.
+var lookupSRV = net.LookupSRV
+
+var srvAddrs struct {
+	sync.RWMutex
+	addrs []string
+}
+
+// TransferAddrs returns the addresses of the transfers to send to: those
+// last resolved from transfer.srv if it is set and has been resolved,
+// transfer.addrs otherwise.
+func TransferAddrs() []string {
+	srvAddrs.RLock()
+	defer srvAddrs.RUnlock()
+	if len(srvAddrs.addrs) > 0 {
+		return srvAddrs.addrs
+	}
+	return Config().Transfer.Addrs
+}
+
+// InitTransferDiscovery resolves transfer.srv, if set, and keeps resolving it
+// every transfer.srvInterval seconds so that transfers added or removed from
+// the record are picked up.
+func InitTransferDiscovery() {
+	if Config().Transfer == nil || Config().Transfer.Srv == "" {
+		return
+	}
+	name := Config().Transfer.Srv
+	resolveTransferAddrs(name)
+	interval := Config().Transfer.SrvInterval
+	if interval <= 0 {
+		interval = defaultSrvInterval
+	}
+	go func() {
+		for range time.Tick(time.Duration(interval) * time.Second) {
+			resolveTransferAddrs(name)
+		}
+	}()
+}
+
+// resolveTransferAddrs replaces the transfer addresses with the targets of
+// the SRV record name and closes the clients of targets that went away. On
+// failure the previous addresses are kept.
+func resolveTransferAddrs(name string) {
+	_, srvs, err := lookupSRV("", "", name)
+	if err != nil || len(srvs) == 0 {
+		log.Println("resolve transfer.srv", name, "fail:", err, "keeping", TransferAddrs())
+		return
+	}
+	addrs := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	srvAddrs.Lock()
+	srvAddrs.addrs = addrs
+	srvAddrs.Unlock()
+	closeTransferClients(addrs)
+}
@@ -0,0 +1,102 @@
+package g
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/open-falcon/common/model"
+)
+
+// fakeSRV returns a lookupSRV answering with the targets of addrs.
+func fakeSRV(t *testing.T, addrs ...string) func(service, proto, name string) (string, []*net.SRV, error) {
+	var srvs []*net.SRV
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srvs = append(srvs, &net.SRV{Target: host + ".", Port: uint16(p)})
+	}
+	return func(service, proto, name string) (string, []*net.SRV, error) {
+		if name != "_transfer._tcp.example.com" {
+			return "", nil, errors.New("no such host")
+		}
+		return "", srvs, nil
+	}
+}
+
+func resetSrvAddrs() {
+	lookupSRV = net.LookupSRV
+	srvAddrs.Lock()
+	srvAddrs.addrs = nil
+	srvAddrs.Unlock()
+}
+
+func TestTransferSrvBalances(t *testing.T) {
+	addr1, conns1 := serveTransfer(t)
+	addr2, conns2 := serveTransfer(t)
+	lookupSRV = fakeSRV(t, addr1, addr2)
+	defer resetSrvAddrs()
+	lock.Lock()
+	config = &GlobalConfig{Transfer: &TransferConfig{Addrs: []string{"127.0.0.1:1"}, Srv: "_transfer._tcp.example.com", Timeout: 1000}}
+	lock.Unlock()
+
+	resolveTransferAddrs(Config().Transfer.Srv)
+	if addrs := TransferAddrs(); len(addrs) != 2 || addrs[0] != addr1 || addrs[1] != addr2 {
+		t.Fatalf("expected the SRV targets %s and %s, got %v", addr1, addr2, addrs)
+	}
+	for i := 0; i < 50; i++ {
+		var resp model.TransferResponse
+		SendMetrics([]*model.MetricValue{{Endpoint: "host", Metric: "cpu.idle", Value: 1}}, &resp)
+		if resp.Total != 1 {
+			t.Fatalf("send %d: expected the metric to be transferred, got %v", i, &resp)
+		}
+	}
+	if atomic.LoadInt32(conns1) == 0 || atomic.LoadInt32(conns2) == 0 {
+		t.Errorf("expected sends to both SRV targets, got %d and %d connections", atomic.LoadInt32(conns1), atomic.LoadInt32(conns2))
+	}
+}
+
+func TestTransferSrvFallback(t *testing.T) {
+	lookupSRV = fakeSRV(t)
+	defer resetSrvAddrs()
+	lock.Lock()
+	config = &GlobalConfig{Transfer: &TransferConfig{Addrs: []string{"10.0.0.1:8433"}, Srv: "_missing._tcp.example.com"}}
+	lock.Unlock()
+
+	resolveTransferAddrs(Config().Transfer.Srv)
+	if addrs := TransferAddrs(); len(addrs) != 1 || addrs[0] != "10.0.0.1:8433" {
+		t.Errorf("expected the static addresses while the record does not resolve, got %v", addrs)
+	}
+}
+
+func TestTransferSrvClosesRemovedTargets(t *testing.T) {
+	addr1, _ := serveTransfer(t)
+	addr2, _ := serveTransfer(t)
+	lookupSRV = fakeSRV(t, addr1, addr2)
+	defer resetSrvAddrs()
+	lock.Lock()
+	config = &GlobalConfig{Transfer: &TransferConfig{Srv: "_transfer._tcp.example.com", Timeout: 1000}}
+	lock.Unlock()
+
+	resolveTransferAddrs(Config().Transfer.Srv)
+	transferClient(addr1)
+	transferClient(addr2)
+
+	lookupSRV = fakeSRV(t, addr2)
+	resolveTransferAddrs(Config().Transfer.Srv)
+	TransferClientsLock.RLock()
+	_, ok1 := TransferClients[addr1]
+	_, ok2 := TransferClients[addr2]
+	TransferClientsLock.RUnlock()
+	if ok1 || !ok2 {
+		t.Errorf("expected only the client of the remaining target %s, got %v", addr2, TransferClients)
+	}
+}
@@ -10,36 +10,59 @@ import (
 )
 
 var (
-	TransferClientsLock *sync.RWMutex = new(sync.RWMutex)
-        TransferClients map[string]*SingleConnRpcClient = map[string]*SingleConnRpcClient{}
+	TransferClientsLock *sync.RWMutex                   = new(sync.RWMutex)
+	TransferClients     map[string]*SingleConnRpcClient = map[string]*SingleConnRpcClient{}
 )
 
 func SendMetrics(metrics []*model.MetricValue, resp *model.TransferResponse) {
 	rand.Seed(time.Now().UnixNano())
-	for _, i := range rand.Perm(len(Config().Transfer.Addrs)) {
-		addr := Config().Transfer.Addrs[i]
-		if _, ok := TransferClients[addr]; !ok {
-			initTransferClient(addr)
-		}
-		if updateMetrics(addr, metrics, resp) {
+	addrs := TransferAddrs()
+	for _, i := range rand.Perm(len(addrs)) {
+		if updateMetrics(addrs[i], metrics, resp) {
 			break
 		}
 	}
 }
 
-func initTransferClient(addr string) {
+// transferClient returns the client of the transfer at addr, creating it on
+// first use.
+func transferClient(addr string) *SingleConnRpcClient {
 	TransferClientsLock.Lock()
 	defer TransferClientsLock.Unlock()
-	TransferClients[addr] = &SingleConnRpcClient{
-		RpcServer: addr,
-		Timeout:   time.Duration(Config().Transfer.Timeout) * time.Millisecond,
+	client, ok := TransferClients[addr]
+	if !ok {
+		client = &SingleConnRpcClient{
+			RpcServer:   addr,
+			Timeout:     time.Duration(Config().Transfer.Timeout) * time.Millisecond,
+			IdleTimeout: time.Duration(Config().Transfer.IdleTimeout) * time.Second,
+		}
+		TransferClients[addr] = client
+	}
+	return client
+}
+
+// closeTransferClients closes and removes the clients of the transfers not
+// in addrs.
+func closeTransferClients(addrs []string) {
+	keep := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		keep[addr] = true
+	}
+	TransferClientsLock.Lock()
+	defer TransferClientsLock.Unlock()
+	for addr, client := range TransferClients {
+		if keep[addr] {
+			continue
+		}
+		client.Lock()
+		client.close()
+		client.Unlock()
+		delete(TransferClients, addr)
 	}
 }
 
 func updateMetrics(addr string, metrics []*model.MetricValue, resp *model.TransferResponse) bool {
-	TransferClientsLock.RLock()
-	defer TransferClientsLock.RUnlock()
-	err := TransferClients[addr].Call("Transfer.Update", metrics, resp)
+	err := transferClient(addr).Call("Transfer.Update", metrics, resp)
 	if err != nil {
 		log.Println("call Transfer.Update fail", addr, err)
 		return false
@@ -0,0 +1,79 @@
+package g
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/open-falcon/common/model"
+)
+
+type fakeTransfer struct{}
+
+func (fakeTransfer) Update(metrics []*model.MetricValue, resp *model.TransferResponse) error {
+	resp.Total = len(metrics)
+	return nil
+}
+
+// serveTransfer serves a fake transfer on a local port and returns its
+// address and a counter of the accepted connections.
+func serveTransfer(t *testing.T) (string, *int32) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Transfer", fakeTransfer{}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns int32
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			go srv.ServeCodec(jsonrpc.NewServerCodec(c))
+		}
+	}()
+	return l.Addr().String(), &conns
+}
+
+func TestSendMetricsReusesConnection(t *testing.T) {
+	addr, conns := serveTransfer(t)
+	lock.Lock()
+	config = &GlobalConfig{Transfer: &TransferConfig{Addrs: []string{addr}, Timeout: 1000}}
+	lock.Unlock()
+
+	for i := 0; i < 5; i++ {
+		var resp model.TransferResponse
+		SendMetrics([]*model.MetricValue{{Endpoint: "host", Metric: "cpu.idle", Value: 1}}, &resp)
+		if resp.Total != 1 {
+			t.Fatalf("send %d: expected the metric to be transferred, got %v", i, &resp)
+		}
+	}
+	if n := atomic.LoadInt32(conns); n != 1 {
+		t.Errorf("expected one connection for all sends, got %d", n)
+	}
+}
+
+func TestRpcClientIdleTimeout(t *testing.T) {
+	addr, conns := serveTransfer(t)
+	c := &SingleConnRpcClient{RpcServer: addr, Timeout: time.Second, IdleTimeout: 10 * time.Millisecond}
+
+	var resp model.TransferResponse
+	if err := c.Call("Transfer.Update", []*model.MetricValue{}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := c.Call("Transfer.Update", []*model.MetricValue{}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(conns); n != 2 {
+		t.Errorf("expected the idle connection to be dialed again, got %d connections", n)
+	}
+}
@@ -70,6 +70,10 @@ func SendToTransfer(metrics []*model.MetricValue) {
 	var resp model.TransferResponse
 	SendMetrics(metrics, &resp)
 
+	for _, s := range Sinks() {
+		s.Send(metrics)
+	}
+
 	if debug {
 		log.Println("<=", &resp)
 	}
@@ -201,7 +205,7 @@ func UpdateCurrentContainers() {
 	}
 	dockerContainers, err := GetCotainerClient().AllDockerContainers(query)
 	if err != nil {
-		log.Println("Get docker containers error : %s", err.Error())
+		log.Printf("Get docker containers error : %s", err.Error())
 		return
 	}
 	containers := make([]string, 0)
@@ -248,7 +252,7 @@ func SetK8sStat(setK8sStat []*dto.MetricFamily) {
 func UpdateK8sStat() {
 	mfs, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
-		log.Println("Get kubernetes info err : %s", err.Error())
+		log.Printf("Get kubernetes info err : %s", err.Error())
 	} else {
 		SetK8sStat(mfs)
 	}
@@ -1,5 +1,7 @@
 module github.com/domeos/agent
 
+go 1.27.1
+
 require (
 	github.com/PuerkitoBio/purell v1.1.0
 	github.com/PuerkitoBio/urlesc v0.0.0-20160726150825-5bd2802263f2
@@ -9,7 +11,6 @@ require (
 	github.com/coreos/pkg v0.0.0-20161026222926-447b7ec906e5
 	github.com/davecgh/go-spew v1.1.0
 	github.com/docker/distribution v2.5.1+incompatible
-	github.com/domeos/kube_event_watcher v0.0.0-20171016082023-c94b41126a7a // indirect
 	github.com/emicklei/go-restful v0.0.0-20161116140610-858e58f98abd
 	github.com/ghodss/yaml v0.0.0-20161020005002-bea76d6a4713
 	github.com/go-openapi/jsonpointer v0.0.0-20161105161541-8d96a2dc6153
@@ -43,10 +44,15 @@ require (
 	golang.org/x/crypto v0.0.0-20161202060003-8a549a1948fc
 	golang.org/x/net v0.0.0-20161202001143-97edce0b2e42
 	golang.org/x/oauth2 v0.0.0-20161130174631-f6093e37b6cb
-	golang.org/x/sys v0.0.0-20161204224327-f5a6bd43051d
+	golang.org/x/sys v0.46.0
 	golang.org/x/text v0.0.0-20161130145921-5c6cf4f9a235
 	google.golang.org/appengine v0.0.0-20161115221414-ca59ef35f409
 	gopkg.in/inf.v0 v0.9.0
 	gopkg.in/yaml.v2 v2.0.0-20160928153709-a5b47d31c556
 	k8s.io/client-go v0.0.0-20161124081551-5d8c36c93cf5
 )
+
+require (
+	cloud.google.com/go/compute/metadata v0.10.0 // indirect
+	github.com/domeos/kube_event_watcher v0.0.0-20171016082023-c94b41126a7a // indirect
+)
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"container/list"
+	"github.com/open-falcon/common/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxSeenEndpoints = 10000
+	seenEndpointTTL  = time.Hour
+)
+
+var seenEndpoints = newEndpointTracker(maxSeenEndpoints, seenEndpointTTL)
+
+var endpointLastPushDesc = prometheus.NewDesc(
+	"agent_endpoint_last_push_timestamp_seconds",
+	"Unix time each endpoint last pushed metrics at. Endpoints are dropped an hour after their last push.",
+	[]string{"endpoint"}, nil,
+)
+
+// endpointTracker remembers when each endpoint last pushed metrics. It
+// forgets endpoints not seen for ttl, and the longest unseen one when it
+// holds max endpoints. The endpoints are kept in order of their last push,
+// so both take constant time per push.
+type endpointTracker struct {
+	sync.Mutex
+	// order holds a *seenEndpoint per endpoint, most recently pushed first.
+	order *list.List
+	seen  map[string]*list.Element
+	max   int
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+type seenEndpoint struct {
+	endpoint string
+	ts       time.Time
+}
+
+func newEndpointTracker(max int, ttl time.Duration) *endpointTracker {
+	return &endpointTracker{order: list.New(), seen: map[string]*list.Element{}, max: max, ttl: ttl, now: time.Now}
+}
+
+func (t *endpointTracker) observe(metrics []*model.MetricValue) {
+	t.Lock()
+	defer t.Unlock()
+	now := t.now()
+	for _, v := range metrics {
+		if e, ok := t.seen[v.Endpoint]; ok {
+			e.Value.(*seenEndpoint).ts = now
+			t.order.MoveToFront(e)
+			continue
+		}
+		if len(t.seen) >= t.max {
+			t.expire(now)
+			if len(t.seen) >= t.max {
+				t.evictOldest()
+			}
+		}
+		t.seen[v.Endpoint] = t.order.PushFront(&seenEndpoint{endpoint: v.Endpoint, ts: now})
+	}
+}
+
+// lastSeen returns the unix time each endpoint last pushed at.
+func (t *endpointTracker) lastSeen() map[string]int64 {
+	t.Lock()
+	defer t.Unlock()
+	t.expire(t.now())
+	res := make(map[string]int64, len(t.seen))
+	for endpoint, e := range t.seen {
+		res[endpoint] = e.Value.(*seenEndpoint).ts.Unix()
+	}
+	return res
+}
+
+// Describe implements the prometheus.Collector interface.
+func (t *endpointTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- endpointLastPushDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (t *endpointTracker) Collect(ch chan<- prometheus.Metric) {
+	for endpoint, ts := range t.lastSeen() {
+		ch <- prometheus.MustNewConstMetric(endpointLastPushDesc, prometheus.GaugeValue, float64(ts), endpoint)
+	}
+}
+
+func (t *endpointTracker) expire(now time.Time) {
+	for e := t.order.Back(); e != nil && now.Sub(e.Value.(*seenEndpoint).ts) > t.ttl; e = t.order.Back() {
+		t.remove(e)
+	}
+}
+
+func (t *endpointTracker) evictOldest() {
+	if e := t.order.Back(); e != nil {
+		t.remove(e)
+	}
+}
+
+func (t *endpointTracker) remove(e *list.Element) {
+	t.order.Remove(e)
+	delete(t.seen, e.Value.(*seenEndpoint).endpoint)
+}
+
+func configEndpointsRoutes() {
+	prometheus.MustRegister(seenEndpoints)
+	http.HandleFunc("/v1/endpoints", func(w http.ResponseWriter, req *http.Request) {
+		RenderJson(w, seenEndpoints.lastSeen())
+	})
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/open-falcon/common/model"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestEndpointsLastSeen(t *testing.T) {
+	setConfig(t, testConfig)
+	for _, endpoint := range []string{"host-a", "host-b"} {
+		body := `[{"endpoint":"` + endpoint + `","metric":"m","value":1,"counterType":"GAUGE"}]`
+		if rec := post("/v1/push", body); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/endpoints", nil))
+	var seen map[string]int64
+	if err := json.Unmarshal(rec.Body.Bytes(), &seen); err != nil {
+		t.Fatalf("decoding %s failed: %s", rec.Body, err)
+	}
+	for _, endpoint := range []string{"host-a", "host-b"} {
+		if ts, ok := seen[endpoint]; !ok || time.Since(time.Unix(ts, 0)) > time.Minute {
+			t.Errorf("%s: expected a recent last seen time, got %v", endpoint, ts)
+		}
+	}
+}
+
+func TestEndpointLastPushTimestamp(t *testing.T) {
+	setConfig(t, testConfig)
+	body := `[{"endpoint":"host-c","metric":"m","value":1,"counterType":"GAUGE"}]`
+	if rec := post("/v1/push", body); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %s", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "agent_endpoint_last_push_timestamp_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetLabel()[0].GetValue() != "host-c" {
+				continue
+			}
+			ts := time.Unix(int64(m.GetGauge().GetValue()), 0)
+			if d := time.Since(ts); d < 0 || d > time.Minute {
+				t.Errorf("expected the last push to be near now, got %s", ts)
+			}
+			return
+		}
+	}
+	t.Error("no last push timestamp for host-c")
+}
+
+func TestEndpointTrackerBounds(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tr := newEndpointTracker(2, time.Minute)
+	tr.now = func() time.Time { return now }
+	push := func(endpoint string) {
+		tr.observe([]*model.MetricValue{{Endpoint: endpoint}})
+		now = now.Add(time.Second)
+	}
+
+	push("a")
+	push("b")
+	push("a")
+	push("c")
+	if seen := tr.lastSeen(); len(seen) != 2 || seen["b"] != 0 || seen["a"] == 0 {
+		t.Errorf("expected the longest unseen endpoint to be evicted, got %v", seen)
+	}
+
+	now = now.Add(time.Hour)
+	push("d")
+	if seen := tr.lastSeen(); len(seen) != 1 || seen["d"] == 0 {
+		t.Errorf("expected expired endpoints to be dropped, got %v", seen)
+	}
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/open-falcon/common/model"
+	"github.com/open-falcon/common/utils"
+	"net/http"
+	"strconv"
+)
+
+// pushedHistogram is a histogram pre-bucketed by the client. Counts[i] is
+// the number of observations above Buckets[i-1] and up to Buckets[i]; an
+// extra last count holds those above the highest bound.
+type pushedHistogram struct {
+	Endpoint  string    `json:"endpoint"`
+	Metric    string    `json:"metric"`
+	Tags      string    `json:"tags"`
+	Timestamp int64     `json:"timestamp"`
+	Step      int64     `json:"step"`
+	Buckets   []float64 `json:"buckets"`
+	Counts    []uint64  `json:"counts"`
+	Sum       float64   `json:"sum"`
+	// Type is the counterType of the expanded series, COUNTER by default
+	// as histograms usually accumulate since the client started.
+	Type string `json:"counterType"`
+}
+
+func (h *pushedHistogram) validate() error {
+	if len(h.Buckets) == 0 {
+		return errors.New("no buckets")
+	}
+	for i := 1; i < len(h.Buckets); i++ {
+		if h.Buckets[i] <= h.Buckets[i-1] {
+			return errors.New("buckets are not increasing")
+		}
+	}
+	if len(h.Counts) != len(h.Buckets) && len(h.Counts) != len(h.Buckets)+1 {
+		return fmt.Errorf("%d counts for %d buckets", len(h.Counts), len(h.Buckets))
+	}
+	return nil
+}
+
+// expand turns h into the series of a Prometheus histogram: cumulative
+// <metric>_bucket series tagged with their upper bound le, including +Inf,
+// <metric>_sum and <metric>_count.
+func (h *pushedHistogram) expand() []*model.MetricValue {
+	series := func(suffix string, value float64, tags string) *model.MetricValue {
+		counterType := h.Type
+		if counterType == "" {
+			counterType = "COUNTER"
+		}
+		return &model.MetricValue{
+			Endpoint:  h.Endpoint,
+			Metric:    h.Metric + suffix,
+			Value:     value,
+			Step:      h.Step,
+			Type:      counterType,
+			Tags:      tags,
+			Timestamp: h.Timestamp,
+		}
+	}
+	bucketTags := func(le string) string {
+		tags := utils.DictedTagstring(h.Tags)
+		tags["le"] = le
+		return utils.SortedTags(tags)
+	}
+
+	metrics := make([]*model.MetricValue, 0, len(h.Buckets)+3)
+	var cumulative uint64
+	for i, bound := range h.Buckets {
+		cumulative += h.Counts[i]
+		metrics = append(metrics, series("_bucket", float64(cumulative), bucketTags(strconv.FormatFloat(bound, 'g', -1, 64))))
+	}
+	if len(h.Counts) > len(h.Buckets) {
+		cumulative += h.Counts[len(h.Buckets)]
+	}
+	return append(metrics,
+		series("_bucket", float64(cumulative), bucketTags("+Inf")),
+		series("_sum", h.Sum, h.Tags),
+		series("_count", float64(cumulative), h.Tags),
+	)
+}
+
+// configHistogramRoutes registers /v1/push/histogram, which expands pushed
+// histograms into series following the Prometheus naming and forwards them
+// like /v1/push.
+func configHistogramRoutes() {
+	http.HandleFunc("/v1/push/histogram", func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength == 0 {
+			http.Error(w, "body is blank", http.StatusBadRequest)
+			return
+		}
+
+		var histograms []*pushedHistogram
+		if err := decodePushBody(w, req, &histograms); err != nil {
+			http.Error(w, err.Error(), pushBodyStatus(err))
+			return
+		}
+		var metrics []*model.MetricValue
+		for i, h := range histograms {
+			if h == nil {
+				http.Error(w, fmt.Sprintf("histogram %d: histogram is null", i), http.StatusBadRequest)
+				return
+			}
+			if err := h.validate(); err != nil {
+				http.Error(w, fmt.Sprintf("histogram %d: %s", i, err), http.StatusBadRequest)
+				return
+			}
+			metrics = append(metrics, h.expand()...)
+		}
+		if len(metrics) == 0 {
+			RenderJson(w, map[string]int{"accepted": 0})
+			return
+		}
+		forwardPushed(w, req, metrics)
+	})
+}
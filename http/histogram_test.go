@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+)
+
+func TestPushHistogram(t *testing.T) {
+	var sent []*model.MetricValue
+	sendToTransfer = func(metrics []*model.MetricValue) { sent = append(sent, metrics...) }
+	defer func() { sendToTransfer = g.SendToTransfer }()
+
+	setConfig(t, testConfig)
+	body := `[{"endpoint":"host","metric":"latency","tags":"api=get","step":60,
+		"buckets":[0.1,0.5,1],"counts":[3,5,1,2],"sum":7.5}]`
+	if rec := post("/v1/push/histogram", body); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	type series struct {
+		metric, tags string
+		value        float64
+	}
+	var got []series
+	for _, m := range sent {
+		if m.Endpoint != "host" || m.Type != "COUNTER" || m.Step != 60 {
+			t.Errorf("unexpected series %v", m)
+		}
+		got = append(got, series{m.Metric, m.Tags, m.Value.(float64)})
+	}
+	want := []series{
+		{"latency_bucket", "api=get,le=0.1", 3},
+		{"latency_bucket", "api=get,le=0.5", 8},
+		{"latency_bucket", "api=get,le=1", 9},
+		{"latency_bucket", "api=get,le=+Inf", 11},
+		{"latency_sum", "api=get", 7.5},
+		{"latency_count", "api=get", 11},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected series %v, got %v", want, got)
+	}
+
+	if rec := post("/v1/push/histogram", `[{"metric":"latency","buckets":[1,0.5],"counts":[1,1]}]`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for decreasing buckets, got %d", rec.Code)
+	}
+}
@@ -24,6 +24,10 @@ func init() {
 	configPageRoutes()
 	configPluginRoutes()
 	configPushRoutes()
+	configHistogramRoutes()
+	configValidateRoutes()
+	configEndpointsRoutes()
+	configOpenTSDBRoutes()
 	configRunRoutes()
 	configSystemRoutes()
 	configContainerRoutes()
@@ -67,6 +71,8 @@ func Start() {
 		return
 	}
 
+	initPushQueue()
+
 	s := &http.Server{
 		Addr:           addr,
 		MaxHeaderBytes: 1 << 30,
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/domeos/agent/g"
+)
+
+const testConfig = `{
+	"hostname": "test-host",
+	"transfer": {"enabled": false, "addrs": []},
+	"http": {"enabled": false}
+}`
+
+// setConfig loads cfg as the global agent configuration.
+func setConfig(t *testing.T, cfg string) {
+	f, err := ioutil.TempFile("", "agent-cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(cfg); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	g.ParseConfig(f.Name())
+}
+
+func post(path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	http.DefaultServeMux.ServeHTTP(rec, req)
+	return rec
+}
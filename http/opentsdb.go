@@ -0,0 +1,79 @@
+package http
+
+import (
+	"bytes"
+	"github.com/open-falcon/common/model"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// openTSDBPoint is a data point as sent to OpenTSDB's /api/put.
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     interface{}       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+func configOpenTSDBRoutes() {
+	http.HandleFunc("/v1/push/opentsdb", func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength == 0 {
+			http.Error(w, "body is blank", http.StatusBadRequest)
+			return
+		}
+
+		bs, err := readPushBody(w, req)
+		if err != nil {
+			http.Error(w, err.Error(), pushBodyStatus(err))
+			return
+		}
+
+		// OpenTSDB accepts a single data point or an array of them.
+		var points []*openTSDBPoint
+		if trimmed := bytes.TrimSpace(bs); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = decodePushJson(bs, &points)
+		} else {
+			var p openTSDBPoint
+			err = decodePushJson(bs, &p)
+			points = append(points, &p)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		forwardPushed(w, req, openTSDBToMetrics(points))
+	})
+}
+
+// openTSDBToMetrics converts points to falcon metrics. Endpoint and step are
+// left for completeMetrics to fill in as for /v1/push.
+func openTSDBToMetrics(points []*openTSDBPoint) []*model.MetricValue {
+	metrics := make([]*model.MetricValue, 0, len(points))
+	for _, p := range points {
+		ts := p.Timestamp
+		// OpenTSDB timestamps may be given in milliseconds.
+		if ts > 1e12 {
+			ts /= 1000
+		}
+		metrics = append(metrics, &model.MetricValue{
+			Metric:    p.Metric,
+			Value:     p.Value,
+			Type:      "GAUGE",
+			Tags:      openTSDBTags(p.Tags),
+			Timestamp: ts,
+		})
+	}
+	return metrics
+}
+
+// openTSDBTags renders tags in falcon's "k1=v1,k2=v2" form, sorted by key.
+func openTSDBTags(tags map[string]string) string {
+	kvs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+	return strings.Join(kvs, ",")
+}
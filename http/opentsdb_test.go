@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPushOpenTSDB(t *testing.T) {
+	setConfig(t, testConfig)
+
+	single := `{"metric":"sys.cpu.user","timestamp":1356998400,"value":42.5,"tags":{"host":"web01","cpu":"0"}}`
+	array := `[` + single + `,{"metric":"sys.cpu.nice","timestamp":1356998400000,"value":18,"tags":{"host":"web01"}}]`
+	for _, body := range []string{single, array} {
+		if rec := post("/v1/push/opentsdb", body); rec.Code != http.StatusOK {
+			t.Errorf("expected 200 for %s, got %d %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	metrics := openTSDBToMetrics([]*openTSDBPoint{
+		{Metric: "sys.cpu.user", Timestamp: 1356998400000, Value: 42.5, Tags: map[string]string{"host": "web01", "cpu": "0"}},
+	})
+	m := metrics[0]
+	if m.Metric != "sys.cpu.user" || m.Tags != "cpu=0,host=web01" || m.Timestamp != 1356998400 {
+		t.Errorf("unexpected conversion %v", m)
+	}
+}
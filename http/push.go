@@ -1,36 +1,396 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/domeos/agent/g"
 	"github.com/open-falcon/common/model"
+	"github.com/open-falcon/common/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
+	"regexp"
+	"strings"
+	"sync"
 )
 
+const (
+	defaultMaxPushBytes = 10 << 20
+	defaultMaxPushDepth = 8
+	defaultPushStep     = 60
+
+	validationModeOpen   = "open"
+	validationModeClosed = "closed"
+
+	endpointSourceHostname   = "hostname"
+	endpointSourceRemoteAddr = "remote-addr"
+	endpointSourceHeader     = "header"
+	defaultEndpointHeader    = "X-Forwarded-For"
+)
+
+// sendToTransfer forwards pushed metrics; tests replace it.
+var sendToTransfer = g.SendToTransfer
+
+var pushRequestBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "agent_push_request_bytes",
+	Help:    "Size of the bodies pushed to /v1/push in bytes.",
+	Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+})
+
+var pushMetricsForwarded = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "agent_push_metrics_forwarded_total",
+	Help: "The number of pushed metrics handed to transfer.",
+})
+
+// configPushRoutes registers the push metrics with the default registry,
+// which the k8s metrics server gathers as well.
 func configPushRoutes() {
+	prometheus.MustRegister(pushRequestBytes, pushSampledDropped, pushMetricsForwarded, pushTimestampRejected)
+
 	http.HandleFunc("/v1/push", func(w http.ResponseWriter, req *http.Request) {
 		if req.ContentLength == 0 {
 			http.Error(w, "body is blank", http.StatusBadRequest)
 			return
 		}
 
-		decoder := json.NewDecoder(req.Body)
-		var metrics []*model.MetricValue
-		err := decoder.Decode(&metrics)
+		bs, err := readPushBody(w, req)
 		if err != nil {
-			http.Error(w, "connot decode body", http.StatusBadRequest)
+			http.Error(w, err.Error(), pushBodyStatus(err))
+			return
+		}
+		pushRequestBytes.Observe(float64(len(bs)))
+		if err := checkPushSchema(bs); err != nil {
+			http.Error(w, err.Error(), pushBodyStatus(err))
+			return
+		}
+		var metrics []*model.MetricValue
+		if err := decodePushJson(bs, &metrics); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(metrics) == 0 {
+			if g.Config().Http.RejectEmptyPush {
+				http.Error(w, "no metrics in body", http.StatusBadRequest)
+				return
+			}
+			RenderJson(w, map[string]int{"accepted": 0})
 			return
 		}
 
-		for _, v := range metrics {
-			if v.Endpoint == "" {
-				v.Endpoint = g.Config().Hostname
+		forwardPushed(w, req, metrics)
+	})
+}
+
+// forwardPushed validates and completes the metrics pushed by req, then
+// forwards them to transfer.
+func forwardPushed(w http.ResponseWriter, req *http.Request, metrics []*model.MetricValue) {
+	metrics, err := validateMetrics(metrics)
+	if err == nil {
+		metrics, err = checkTimestamps(metrics)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	completeMetrics(metrics, defaultEndpoint(req))
+	//log.Printf("auto complete endpoint=> <Total=%d> %v\n", len(metrics), metrics[0])
+	seenEndpoints.observe(metrics)
+	metrics = sampleMetrics(metrics)
+
+	transferPushed(metrics)
+	pushMetricsForwarded.Add(float64(len(metrics)))
+	w.Write([]byte("success"))
+}
+
+// validateMetrics checks each metric with validateMetric. In the closed
+// http.validationMode the first invalid metric fails the whole batch, in the
+// open mode invalid metrics are dropped and the valid ones returned.
+func validateMetrics(metrics []*model.MetricValue) ([]*model.MetricValue, error) {
+	closed := g.Config().Http.ValidationMode == validationModeClosed
+	valid := metrics[:0]
+	for i, v := range metrics {
+		if err := validateMetric(v); err != nil {
+			if closed {
+				return nil, fmt.Errorf("metric %d: %s", i, err)
 			}
+			if g.Config().Debug {
+				log.Printf("dropping invalid metric %v: %s", v, err)
+			}
+			continue
+		}
+		valid = append(valid, v)
+	}
+	return valid, nil
+}
+
+// metricChecks are the checks of validateMetric per field of a metric.
+var metricChecks = []struct {
+	field string
+	check func(v *model.MetricValue) error
+}{
+	{"metric", func(v *model.MetricValue) error {
+		if v.Metric == "" {
+			return errors.New("metric name is blank")
 		}
-		//log.Printf("auto complete endpoint=> <Total=%d> %v\n", len(metrics), metrics[0])
+		return nil
+	}},
+	{"tags", func(v *model.MetricValue) error {
+		err, _ := utils.SplitTagsString(v.Tags)
+		return err
+	}},
+	{"counterType", func(v *model.MetricValue) error {
+		switch v.Type {
+		case "GAUGE", "COUNTER", "DERIVE":
+			return nil
+		}
+		return fmt.Errorf("invalid counterType %q", v.Type)
+	}},
+	{"timestamp", func(v *model.MetricValue) error {
+		if v.Timestamp < 0 {
+			return fmt.Errorf("negative timestamp %d", v.Timestamp)
+		}
+		return nil
+	}},
+}
 
+// validateMetric checks the name, tags, counter type and timestamp of v,
+// which transfer would otherwise reject or store under a broken key.
+func validateMetric(v *model.MetricValue) error {
+	if v == nil {
+		return errors.New("metric is null")
+	}
+	for _, c := range metricChecks {
+		if err := c.check(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		g.SendToTransfer(metrics)
-		w.Write([]byte("success"))
-	})
+// defaultEndpoint returns the endpoint of metrics pushed by req without one,
+// taken from the source selected by http.endpointSource. It falls back to
+// the agent's hostname if the source is empty.
+func defaultEndpoint(req *http.Request) string {
+	cfg := g.Config().Http
+	endpoint := ""
+	switch cfg.EndpointSource {
+	case endpointSourceRemoteAddr:
+		endpoint = req.RemoteAddr
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			endpoint = host
+		}
+	case endpointSourceHeader:
+		header := cfg.EndpointHeader
+		if header == "" {
+			header = defaultEndpointHeader
+		}
+		// X-Forwarded-For lists the client first, then the proxies.
+		endpoint = strings.TrimSpace(strings.Split(req.Header.Get(header), ",")[0])
+	}
+	if endpoint == "" {
+		endpoint = g.Config().Hostname
+	}
+	return endpoint
+}
+
+// completeMetrics fills in what clients may omit: the endpoint defaults to
+// endpoint, a non-positive step to http.defaultStep, and
+// http.defaultTags are added unless the client sent the same tag.
+func completeMetrics(metrics []*model.MetricValue, endpoint string) {
+	defaultTags := g.Config().Http.DefaultTags
+	step := g.Config().Http.DefaultStep
+	if step <= 0 {
+		step = defaultPushStep
+	}
+	for _, v := range metrics {
+		if v.Endpoint == "" {
+			v.Endpoint = endpoint
+		}
+		v.Endpoint = transformEndpoint(v.Endpoint)
+		if v.Step <= 0 {
+			v.Step = step
+		}
+		if len(defaultTags) > 0 {
+			v.Tags = mergeTags(defaultTags, v.Tags)
+		}
+	}
+}
+
+var endpointRegexp struct {
+	sync.Mutex
+	pattern string
+	re      *regexp.Regexp
+}
+
+// transformEndpoint strips http.endpointStripSuffixes from endpoint and
+// applies the http.endpointRegexp replacement.
+func transformEndpoint(endpoint string) string {
+	cfg := g.Config().Http
+	for _, suffix := range cfg.EndpointStripSuffixes {
+		if suffix != "" && strings.HasSuffix(endpoint, suffix) {
+			endpoint = strings.TrimSuffix(endpoint, suffix)
+			break
+		}
+	}
+	if cfg.EndpointRegexp == "" {
+		return endpoint
+	}
+
+	endpointRegexp.Lock()
+	defer endpointRegexp.Unlock()
+	if endpointRegexp.pattern != cfg.EndpointRegexp {
+		re, err := regexp.Compile(cfg.EndpointRegexp)
+		if err != nil {
+			log.Printf("invalid http.endpointRegexp %q: %s", cfg.EndpointRegexp, err)
+		}
+		endpointRegexp.pattern, endpointRegexp.re = cfg.EndpointRegexp, re
+	}
+	if endpointRegexp.re == nil {
+		return endpoint
+	}
+	return endpointRegexp.re.ReplaceAllString(endpoint, cfg.EndpointReplacement)
+}
+
+// mergeTags returns the tag string of defaults overridden by tags.
+func mergeTags(defaults map[string]string, tags string) string {
+	merged := make(map[string]string, len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range utils.DictedTagstring(tags) {
+		merged[k] = v
+	}
+	return utils.SortedTags(merged)
+}
+
+// decodePushBody decodes the request body into v after the checks of
+// readPushBody.
+func decodePushBody(w http.ResponseWriter, req *http.Request, v interface{}) error {
+	bs, err := readPushBody(w, req)
+	if err != nil {
+		return err
+	}
+	// The bytes read rather than ContentLength, which is -1 when chunked.
+	pushRequestBytes.Observe(float64(len(bs)))
+	return decodePushJson(bs, v)
+}
+
+// errDecompressedTooLarge is returned for gzip encoded push bodies expanding
+// beyond http.maxDecompressedBytes.
+var errDecompressedTooLarge = errors.New("decompressed body is too large")
+
+// pushBodyStatus returns the status code of the response to a push body
+// rejected with err.
+func pushBodyStatus(err error) int {
+	switch err {
+	case errDecompressedTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case errSchemaUnavailable:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// readPushBody reads the request body, rejecting bodies larger than
+// http.maxPushBytes or nested deeper than http.maxPushDepth before they reach
+// the decoder. Bodies with a gzip Content-Encoding are decompressed.
+func readPushBody(w http.ResponseWriter, req *http.Request) ([]byte, error) {
+	cfg := g.Config().Http
+
+	maxBytes := cfg.MaxPushBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxPushBytes
+	}
+	bs, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxBytes)
+	}
+
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		maxDecompressed := cfg.MaxDecompressedBytes
+		if maxDecompressed <= 0 {
+			maxDecompressed = maxBytes
+		}
+		if bs, err = gunzip(bs, maxDecompressed); err != nil {
+			return nil, err
+		}
+	}
+
+	maxDepth := cfg.MaxPushDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxPushDepth
+	}
+	if jsonDepth(bs) > maxDepth {
+		return nil, fmt.Errorf("body is nested deeper than %d levels", maxDepth)
+	}
+	return bs, nil
+}
+
+// gunzip decompresses bs, giving up with errDecompressedTooLarge as soon as
+// it expands beyond max bytes, so that small bombs cannot exhaust memory.
+func gunzip(bs []byte, max int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, errors.New("body is not valid gzip")
+	}
+	defer zr.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(zr, max+1))
+	if err != nil {
+		return nil, errors.New("body is not valid gzip")
+	}
+	if int64(len(out)) > max {
+		return nil, errDecompressedTooLarge
+	}
+	return out, nil
+}
+
+// decodePushJson decodes bs into v. Unknown fields are rejected when
+// http.strictPush is set.
+func decodePushJson(bs []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	if g.Config().Http.StrictPush {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return errors.New("connot decode body")
+	}
+	return nil
+}
+
+// jsonDepth returns the maximum nesting of arrays and objects in bs.
+func jsonDepth(bs []byte) int {
+	depth, max := 0, 0
+	inString, escaped := false, false
+	for _, b := range bs {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+			if depth > max {
+				max = depth
+			}
+		case ']', '}':
+			depth--
+		}
+	}
+	return max
 }
@@ -0,0 +1,299 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPushStrictMode(t *testing.T) {
+	body := `[{"metric":"m","value":1,"step":60,"counterType":"GAUGE","unknown":1}]`
+
+	setConfig(t, testConfig)
+	if rec := post("/v1/push", body); rec.Code != http.StatusOK {
+		t.Errorf("lenient mode: expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "strictPush": true}`, 1))
+	if rec := post("/v1/push", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("strict mode: expected 400 for unknown field, got %d", rec.Code)
+	}
+	if rec := post("/v1/push", `[{"metric":"m","value":1,"step":60,"counterType":"GAUGE"}]`); rec.Code != http.StatusOK {
+		t.Errorf("strict mode: expected 200 for valid body, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPushRejectsDeepNesting(t *testing.T) {
+	setConfig(t, testConfig)
+	body := `[{"metric":"m","value":` + strings.Repeat("[", 50) + strings.Repeat("]", 50) + `}]`
+	if rec := post("/v1/push", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for deeply nested body, got %d", rec.Code)
+	}
+}
+
+func TestPushDefaultsStep(t *testing.T) {
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "defaultStep": 30}`, 1))
+	if rec := post("/v1/push", `[{"metric":"m","value":1,"step":0,"counterType":"GAUGE"}]`); rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	metrics := []*model.MetricValue{{Metric: "m", Step: 0}, {Metric: "n", Step: -5}, {Metric: "o", Step: 10}}
+	completeMetrics(metrics, "test-host")
+	for i, want := range []int64{30, 30, 10} {
+		if metrics[i].Step != want {
+			t.Errorf("%s: expected step %d, got %d", metrics[i].Metric, want, metrics[i].Step)
+		}
+	}
+	if metrics[0].Endpoint != "test-host" {
+		t.Errorf("expected endpoint to default to hostname, got %q", metrics[0].Endpoint)
+	}
+
+	setConfig(t, testConfig)
+	metrics = []*model.MetricValue{{Metric: "m"}}
+	completeMetrics(metrics, "test-host")
+	if metrics[0].Step != defaultPushStep {
+		t.Errorf("expected step %d without configuration, got %d", defaultPushStep, metrics[0].Step)
+	}
+}
+
+func TestPushDefaultTags(t *testing.T) {
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "defaultTags": {"region": "us-east", "cluster": "prod"}}`, 1))
+
+	metrics := []*model.MetricValue{{Metric: "m"}, {Metric: "n", Tags: "cluster=staging,app=web"}}
+	completeMetrics(metrics, "test-host")
+	if metrics[0].Tags != "cluster=prod,region=us-east" {
+		t.Errorf("expected default tags, got %q", metrics[0].Tags)
+	}
+	if metrics[1].Tags != "app=web,cluster=staging,region=us-east" {
+		t.Errorf("expected client tags to override defaults, got %q", metrics[1].Tags)
+	}
+}
+
+func TestPushValidationMode(t *testing.T) {
+	mixed := func() []*model.MetricValue {
+		return []*model.MetricValue{
+			{Metric: "good", Type: "GAUGE", Tags: "a=b"},
+			{Metric: "", Type: "GAUGE"},
+			{Metric: "badtags", Type: "GAUGE", Tags: "a"},
+			{Metric: "badtype", Type: "HISTOGRAM"},
+		}
+	}
+	body := `[{"metric":"good","value":1,"counterType":"GAUGE"},{"metric":"bad","value":1,"counterType":"HISTOGRAM"}]`
+
+	setConfig(t, testConfig)
+	valid, err := validateMetrics(mixed())
+	if err != nil {
+		t.Fatalf("open mode: unexpected error %s", err)
+	}
+	if len(valid) != 1 || valid[0].Metric != "good" {
+		t.Errorf("open mode: expected only the valid metric, got %v", valid)
+	}
+	if rec := post("/v1/push", body); rec.Code != http.StatusOK {
+		t.Errorf("open mode: expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "validationMode": "closed"}`, 1))
+	if _, err := validateMetrics(mixed()); err == nil {
+		t.Errorf("closed mode: expected the batch to be rejected")
+	}
+	if rec := post("/v1/push", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("closed mode: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestPushEndpointSource(t *testing.T) {
+	req := httptest.NewRequest("POST", "/v1/push", nil)
+	req.RemoteAddr = "10.0.0.5:41234"
+	req.Header.Set("X-Forwarded-For", "192.168.1.7, 10.0.0.1")
+	req.Header.Set("X-Endpoint", "pod-a")
+
+	for _, tc := range []struct {
+		settings string
+		want     string
+	}{
+		{``, "test-host"},
+		{`, "endpointSource": "hostname"`, "test-host"},
+		{`, "endpointSource": "remote-addr"`, "10.0.0.5"},
+		{`, "endpointSource": "header"`, "192.168.1.7"},
+		{`, "endpointSource": "header", "endpointHeader": "X-Endpoint"`, "pod-a"},
+		{`, "endpointSource": "header", "endpointHeader": "X-Missing"`, "test-host"},
+	} {
+		setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false`+tc.settings+`}`, 1))
+		if got := defaultEndpoint(req); got != tc.want {
+			t.Errorf("%s: expected endpoint %q, got %q", tc.settings, tc.want, got)
+		}
+	}
+}
+
+func TestPushRequestBytes(t *testing.T) {
+	setConfig(t, testConfig)
+	histogram := func() (uint64, float64) {
+		var m dto.Metric
+		if err := pushRequestBytes.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+	}
+	count, sum := histogram()
+
+	bodies := []string{
+		`[{"metric":"m","value":1,"counterType":"GAUGE"}]`,
+		`[{"metric":"m","value":1,"counterType":"GAUGE"},{"metric":"n","value":2,"counterType":"GAUGE"}]`,
+	}
+	size := 0
+	for _, body := range bodies {
+		if rec := post("/v1/push", body); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+		}
+		size += len(body)
+	}
+
+	newCount, newSum := histogram()
+	if newCount-count != 2 || newSum-sum != float64(size) {
+		t.Errorf("expected 2 observations of %d bytes, got %d of %v", size, newCount-count, newSum-sum)
+	}
+}
+
+func TestPushEndpointTransform(t *testing.T) {
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "endpointStripSuffixes": [".internal.example.com"]}`, 1))
+	metrics := []*model.MetricValue{{Metric: "m", Endpoint: "web01.internal.example.com"}, {Metric: "n", Endpoint: "db01.example.org"}}
+	completeMetrics(metrics, "test-host")
+	if metrics[0].Endpoint != "web01" || metrics[1].Endpoint != "db01.example.org" {
+		t.Errorf("expected only the configured suffix stripped, got %q and %q", metrics[0].Endpoint, metrics[1].Endpoint)
+	}
+
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "endpointRegexp": "^([^.]+)\\..*$", "endpointReplacement": "$1"}`, 1))
+	metrics = []*model.MetricValue{{Metric: "m", Endpoint: "web01.internal.example.com"}, {Metric: "n"}}
+	completeMetrics(metrics, "host.example.com")
+	if metrics[0].Endpoint != "web01" || metrics[1].Endpoint != "host" {
+		t.Errorf("expected the regexp applied after auto-completion, got %q and %q", metrics[0].Endpoint, metrics[1].Endpoint)
+	}
+}
+
+func TestPushSampling(t *testing.T) {
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "sampleRules": [{"metric": "^app\\.", "rate": 0.5}]}`, 1))
+
+	series := func() []*model.MetricValue {
+		var metrics []*model.MetricValue
+		for i := 0; i < 1000; i++ {
+			metrics = append(metrics, &model.MetricValue{Endpoint: "host-" + strconv.Itoa(i), Metric: "app.requests"})
+		}
+		return append(metrics, &model.MetricValue{Endpoint: "host", Metric: "cpu.idle"})
+	}
+	dropped := func() float64 {
+		var m dto.Metric
+		if err := pushSampledDropped.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	before := dropped()
+	kept := sampleMetrics(series())
+	if n := len(kept); n < 450 || n > 550 {
+		t.Errorf("expected about half of the series forwarded, got %d of 1000", n)
+	}
+	if d := dropped() - before; d != float64(1001-len(kept)) {
+		t.Errorf("expected %d dropped metrics counted, got %v", 1001-len(kept), d)
+	}
+	if last := kept[len(kept)-1]; last.Metric != "cpu.idle" {
+		t.Errorf("expected metrics not matching a rule to be forwarded, got %v", last)
+	}
+
+	again := sampleMetrics(series())
+	if len(again) != len(kept) {
+		t.Fatalf("expected the same selection, got %d then %d series", len(kept), len(again))
+	}
+	for i := range kept {
+		if kept[i].Endpoint != again[i].Endpoint {
+			t.Fatalf("expected the same selection, got %s then %s", kept[i].Endpoint, again[i].Endpoint)
+		}
+	}
+}
+
+func TestPushMetricsForwarded(t *testing.T) {
+	setConfig(t, testConfig)
+	forwarded := func() float64 {
+		var m dto.Metric
+		if err := pushMetricsForwarded.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	before := forwarded()
+
+	metrics := make([]string, 7)
+	for i := range metrics {
+		metrics[i] = `{"metric":"m` + strconv.Itoa(i) + `","value":1,"counterType":"GAUGE"}`
+	}
+	if rec := post("/v1/push", "["+strings.Join(metrics, ",")+"]"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	if d := forwarded() - before; d != 7 {
+		t.Errorf("expected 7 forwarded metrics, got %v", d)
+	}
+}
+
+func TestPushEmptyArray(t *testing.T) {
+	calls := 0
+	sendToTransfer = func([]*model.MetricValue) { calls++ }
+	defer func() { sendToTransfer = g.SendToTransfer }()
+
+	setConfig(t, testConfig)
+	rec := post("/v1/push", "[]")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"accepted":0}` {
+		t.Errorf("expected 200 accepting nothing, got %d %s", rec.Code, rec.Body)
+	}
+
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "rejectEmptyPush": true}`, 1))
+	if rec := post("/v1/push", "[]"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 when rejecting empty pushes, got %d %s", rec.Code, rec.Body)
+	}
+	if calls != 0 {
+		t.Errorf("expected no transfer call, got %d", calls)
+	}
+}
+
+func gzipped(t *testing.T, s string) *bytes.Buffer {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestPushGzipDecompressedLimit(t *testing.T) {
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "maxDecompressedBytes": 4096}`, 1))
+	postGzip := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/push", gzipped(t, body))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := postGzip(`[{"endpoint":"host","metric":"m","value":1,"counterType":"GAUGE"}]`); rec.Code != http.StatusOK {
+		t.Fatalf("expected a small gzip body to be accepted, got %d %s", rec.Code, rec.Body)
+	}
+
+	// A megabyte of whitespace compresses to about a kilobyte.
+	bomb := "[" + strings.Repeat(" ", 1<<20) + "]"
+	body := gzipped(t, bomb)
+	if body.Len() > 4096 {
+		t.Fatalf("expected the payload to compress below the limit, got %d bytes", body.Len())
+	}
+	if rec := postGzip(bomb); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d %s", rec.Code, rec.Body)
+	}
+}
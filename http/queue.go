@@ -0,0 +1,120 @@
+package http
+
+import (
+	"fmt"
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+	"log"
+	"sync"
+	"time"
+)
+
+const defaultPushDrainTimeout = 10
+
+// pushQueue buffers pushed batches for a worker forwarding them, so that
+// clients do not wait for transfer. Batches arriving while the queue is full
+// or closed are sent synchronously instead of being dropped.
+type pushQueue struct {
+	send func([]*model.MetricValue)
+
+	mu      sync.RWMutex
+	closed  bool
+	batches chan []*model.MetricValue
+	done    chan struct{}
+}
+
+func newPushQueue(size int, send func([]*model.MetricValue)) *pushQueue {
+	q := &pushQueue{
+		send:    send,
+		batches: make(chan []*model.MetricValue, size),
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *pushQueue) run() {
+	for metrics := range q.batches {
+		q.send(metrics)
+	}
+	close(q.done)
+}
+
+// enqueue queues metrics, and reports false if they have to be sent by the
+// caller.
+func (q *pushQueue) enqueue(metrics []*model.MetricValue) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.batches <- metrics:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain stops accepting batches and waits up to timeout for the queued ones
+// to be sent.
+func (q *pushQueue) drain(timeout time.Duration) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.batches)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("%d batches left after %s", len(q.batches), timeout)
+	}
+}
+
+var (
+	pushes     *pushQueue
+	pushesLock = new(sync.Mutex)
+)
+
+// initPushQueue starts the queue of pushed batches if http.pushQueueSize is
+// positive.
+func initPushQueue() {
+	size := g.Config().Http.PushQueueSize
+	if size <= 0 {
+		return
+	}
+	pushesLock.Lock()
+	defer pushesLock.Unlock()
+	pushes = newPushQueue(size, func(metrics []*model.MetricValue) { sendToTransfer(metrics) })
+}
+
+// transferPushed forwards pushed metrics through the queue if there is one.
+func transferPushed(metrics []*model.MetricValue) {
+	pushesLock.Lock()
+	q := pushes
+	pushesLock.Unlock()
+	if q == nil || !q.enqueue(metrics) {
+		sendToTransfer(metrics)
+	}
+}
+
+// DrainPushQueue sends the batches still queued on shutdown, waiting at
+// most http.pushDrainTimeout seconds.
+func DrainPushQueue() {
+	pushesLock.Lock()
+	q := pushes
+	pushesLock.Unlock()
+	if q == nil {
+		return
+	}
+	timeout := g.Config().Http.PushDrainTimeout
+	if timeout <= 0 {
+		timeout = defaultPushDrainTimeout
+	}
+	if err := q.drain(time.Duration(timeout) * time.Second); err != nil {
+		log.Println("draining push queue fail:", err)
+	}
+}
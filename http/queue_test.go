@@ -0,0 +1,45 @@
+package http
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-falcon/common/model"
+)
+
+func TestPushQueueDrain(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	sent := 0
+	q := newPushQueue(10, func(metrics []*model.MetricValue) {
+		<-release
+		mu.Lock()
+		sent += len(metrics)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 5; i++ {
+		if !q.enqueue([]*model.MetricValue{{Metric: "m"}}) {
+			t.Fatalf("batch %d: expected to be queued", i)
+		}
+	}
+
+	// The worker is stuck, so draining times out with batches left.
+	if err := q.drain(10 * time.Millisecond); err == nil {
+		t.Error("expected draining a stuck queue to time out")
+	}
+	if q.enqueue([]*model.MetricValue{{Metric: "m"}}) {
+		t.Error("expected a draining queue to refuse batches")
+	}
+
+	close(release)
+	if err := q.drain(time.Second); err != nil {
+		t.Fatalf("draining failed: %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sent != 5 {
+		t.Errorf("expected all 5 queued metrics to be sent, got %d", sent)
+	}
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"hash/fnv"
+	"log"
+	"math"
+	"regexp"
+	"sync"
+)
+
+var pushSampledDropped = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "agent_push_sampled_dropped_total",
+	Help: "The number of pushed metrics dropped by http.sampleRules.",
+})
+
+var sampleRegexps = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+// sampleRegexp returns the compiled pattern of a sample rule, or nil if it is
+// invalid.
+func sampleRegexp(pattern string) *regexp.Regexp {
+	sampleRegexps.Lock()
+	defer sampleRegexps.Unlock()
+	re, ok := sampleRegexps.m[pattern]
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			log.Printf("invalid http.sampleRules metric %q: %s", pattern, err)
+		}
+		sampleRegexps.m[pattern] = re
+	}
+	return re
+}
+
+// sampleMetrics drops the metrics not selected by the first of the
+// http.sampleRules matching their name. The selection hashes the series, so
+// a series is either always forwarded or always dropped.
+func sampleMetrics(metrics []*model.MetricValue) []*model.MetricValue {
+	rules := g.Config().Http.SampleRules
+	if len(rules) == 0 {
+		return metrics
+	}
+	kept := metrics[:0]
+	for _, v := range metrics {
+		if sampled(v, rules) {
+			kept = append(kept, v)
+		} else {
+			pushSampledDropped.Inc()
+		}
+	}
+	return kept
+}
+
+func sampled(v *model.MetricValue, rules []g.SampleRule) bool {
+	for _, rule := range rules {
+		re := sampleRegexp(rule.Metric)
+		if re == nil || !re.MatchString(v.Metric) {
+			continue
+		}
+		if rule.Rate >= 1 {
+			return true
+		}
+		h := fnv.New64a()
+		h.Write([]byte(v.Endpoint + "\x00" + v.Metric + "\x00" + v.Tags))
+		return float64(h.Sum64())/math.MaxUint64 < rule.Rate
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/domeos/agent/g"
+	"io/ioutil"
+	"log"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// jsonSchema is the subset of JSON Schema the push body can be checked
+// against: type, enum, required, properties, items, minItems, maxItems,
+// minimum, maximum, minLength, maxLength and pattern.
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Enum       []interface{}          `json:"enum"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	MinItems   *int                   `json:"minItems"`
+	MaxItems   *int                   `json:"maxItems"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  *int                   `json:"minLength"`
+	MaxLength  *int                   `json:"maxLength"`
+	Pattern    string                 `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+func loadJsonSchema(path string) (*jsonSchema, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s jsonSchema
+	if err := json.Unmarshal(bs, &s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *jsonSchema) compile() error {
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+	return nil
+}
+
+// validate returns the violations of s by v, the result of decoding JSON
+// into an interface{}, each prefixed with the path of the offending value.
+func (s *jsonSchema) validate(v interface{}, path string) []string {
+	var violations []string
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if s.Type != "" && !jsonType(v, s.Type) {
+		violate("expected %s", s.Type)
+		return violations
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violate("not one of %v", s.Enum)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				violate("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if pv, ok := v[name]; ok {
+				violations = append(violations, s.Properties[name].validate(pv, path+"."+name)...)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			violate("fewer than %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			violate("more than %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				violations = append(violations, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			violate("%v is less than %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			violate("%v is greater than %v", v, *s.Maximum)
+		}
+	case string:
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			violate("shorter than %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(v) > *s.MaxLength {
+			violate("longer than %d", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			violate("%q does not match %q", v, s.Pattern)
+		}
+	}
+	return violations
+}
+
+func jsonType(v interface{}, t string) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && v == math.Trunc(v)
+	case bool:
+		return t == "boolean"
+	case nil:
+		return t == "null"
+	}
+	return false
+}
+
+// errSchemaUnavailable rejects pushes while http.pushSchema cannot be loaded,
+// rather than accepting bodies that were never checked.
+var errSchemaUnavailable = errors.New("schema unavailable")
+
+var pushSchema struct {
+	sync.Mutex
+	path   string
+	schema *jsonSchema
+	err    error
+}
+
+// currentPushSchema returns the schema at http.pushSchema, loading it again
+// when the path changes, or nil if there is none. It fails for as long as the
+// schema at the path is invalid.
+func currentPushSchema() (*jsonSchema, error) {
+	path := g.Config().Http.PushSchema
+	pushSchema.Lock()
+	defer pushSchema.Unlock()
+	if pushSchema.path != path {
+		var s *jsonSchema
+		var err error
+		if path != "" {
+			if s, err = loadJsonSchema(path); err != nil {
+				log.Printf("invalid http.pushSchema %q: %s", path, err)
+			}
+		}
+		pushSchema.path, pushSchema.schema, pushSchema.err = path, s, err
+	}
+	return pushSchema.schema, pushSchema.err
+}
+
+// checkPushSchema checks the push body bs against http.pushSchema.
+func checkPushSchema(bs []byte) error {
+	s, err := currentPushSchema()
+	if err != nil {
+		return errSchemaUnavailable
+	}
+	if s == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(bs, &v); err != nil {
+		return errors.New("connot decode body")
+	}
+	if violations := s.validate(v, "$"); len(violations) > 0 {
+		return fmt.Errorf("schema violations: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPushSchema(t *testing.T) {
+	f, err := ioutil.TempFile("", "push-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{
+		"type": "array",
+		"items": {
+			"type": "object",
+			"required": ["metric", "tags"],
+			"properties": {
+				"tags": {"type": "string", "pattern": "(^|,)service="},
+				"value": {"type": "number", "minimum": 0}
+			}
+		}
+	}`)
+	f.Close()
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "pushSchema": "`+f.Name()+`"}`, 1))
+
+	if rec := post("/v1/push", `[{"metric":"m","value":1,"counterType":"GAUGE","tags":"service=web,idc=a"}]`); rec.Code != http.StatusOK {
+		t.Errorf("expected a conforming body to be accepted, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec := post("/v1/push", `[{"metric":"m","value":1,"counterType":"GAUGE","tags":"idc=a"},{"metric":"m","value":-1,"counterType":"GAUGE"}]`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d %s", rec.Code, rec.Body)
+	}
+	for _, violation := range []string{
+		`$[0].tags: "idc=a" does not match`,
+		`$[1]: missing required property "tags"`,
+		`$[1].value: -1 is less than 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), violation) {
+			t.Errorf("expected %q in %s", violation, rec.Body)
+		}
+	}
+}
+
+func TestPushSchemaUnavailable(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing": "",
+		"invalid": `{"type": `,
+	} {
+		path := os.TempDir() + "/push-schema-" + name
+		if content != "" {
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(path)
+		}
+		setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "pushSchema": "`+path+`"}`, 1))
+
+		rec := post("/v1/push", `[{"metric":"m","value":1,"counterType":"GAUGE"}]`)
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "schema unavailable") {
+			t.Errorf("%s schema: expected 500 schema unavailable, got %d %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestSchemaEnumOfObjects(t *testing.T) {
+	var s jsonSchema
+	if err := json.Unmarshal([]byte(`{"enum": [{"service": "web"}, ["a", "b"], 1]}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	for body, want := range map[string]int{
+		`{"service": "web"}`: 0,
+		`["a", "b"]`:         0,
+		`1`:                  0,
+		`{"service": "db"}`:  1,
+		`["a"]`:              1,
+	} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			t.Fatal(err)
+		}
+		if violations := s.validate(v, "$"); len(violations) != want {
+			t.Errorf("%s: expected %d violations, got %v", body, want, violations)
+		}
+	}
+}
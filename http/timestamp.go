@@ -0,0 +1,91 @@
+package http
+
+import (
+	"fmt"
+	"github.com/domeos/agent/g"
+	"github.com/open-falcon/common/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"time"
+)
+
+const (
+	timestampSkewReject = "reject"
+	timestampSkewClamp  = "clamp"
+)
+
+// pushNow returns the time pushed timestamps are compared to; tests replace
+// it.
+var pushNow = time.Now
+
+var pushTimestampRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "agent_push_timestamp_rejected_total",
+	Help: "The number of pushed metrics with a timestamp beyond http.maxTimestampSkew, by the action taken.",
+}, []string{"action"})
+
+// checkTimestamps handles the metrics whose timestamp is more than
+// http.maxTimestampSkew seconds away from now. With the clamp
+// http.timestampSkewAction their timestamp is moved to the nearest bound.
+// Otherwise they are rejected like invalid metrics: in the closed
+// http.validationMode the whole batch fails, in the open mode they are
+// dropped. Zero timestamps are left for transfer to fill in.
+func checkTimestamps(metrics []*model.MetricValue) ([]*model.MetricValue, error) {
+	b, ok := currentTimestampBounds()
+	if !ok {
+		return metrics, nil
+	}
+	cfg := g.Config().Http
+	clamp := cfg.TimestampSkewAction == timestampSkewClamp
+
+	kept := metrics[:0]
+	for i, v := range metrics {
+		err := b.check(v.Timestamp)
+		if err == nil {
+			kept = append(kept, v)
+			continue
+		}
+		if clamp {
+			pushTimestampRejected.WithLabelValues(timestampSkewClamp).Inc()
+			if v.Timestamp < b.min {
+				v.Timestamp = b.min
+			} else {
+				v.Timestamp = b.max
+			}
+			kept = append(kept, v)
+			continue
+		}
+		pushTimestampRejected.WithLabelValues(timestampSkewReject).Inc()
+		if cfg.ValidationMode == validationModeClosed {
+			return nil, fmt.Errorf("metric %d: %s", i, err)
+		}
+		if g.Config().Debug {
+			log.Printf("dropping metric %v: %s", v, err)
+		}
+	}
+	return kept, nil
+}
+
+// timestampBounds are the timestamps within http.maxTimestampSkew of now.
+type timestampBounds struct {
+	now, skew, min, max int64
+}
+
+// currentTimestampBounds returns the bounds of pushed timestamps, and false if
+// http.maxTimestampSkew does not limit them.
+func currentTimestampBounds() (timestampBounds, bool) {
+	skew := g.Config().Http.MaxTimestampSkew
+	if skew <= 0 {
+		return timestampBounds{}, false
+	}
+	now := pushNow().Unix()
+	return timestampBounds{now: now, skew: skew, min: now - skew, max: now + skew}, true
+}
+
+// check fails for timestamps beyond the bounds. Zero timestamps are left for
+// transfer to fill in.
+func (b timestampBounds) check(ts int64) error {
+	if ts == 0 || (ts >= b.min && ts <= b.max) {
+		return nil
+	}
+	return fmt.Errorf("timestamp %d is more than %ds away from %d", ts, b.skew, b.now)
+}
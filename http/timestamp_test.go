@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-falcon/common/model"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func timestampRejected(t *testing.T, action string) float64 {
+	var m dto.Metric
+	if err := pushTimestampRejected.WithLabelValues(action).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// skewed returns metrics at the bounds of a 60s skew around now and just
+// beyond them, plus one without a timestamp.
+func skewed(now int64) []*model.MetricValue {
+	return []*model.MetricValue{
+		{Metric: "past", Timestamp: now - 60},
+		{Metric: "future", Timestamp: now + 60},
+		{Metric: "tooold", Timestamp: now - 61},
+		{Metric: "toonew", Timestamp: now + 61},
+		{Metric: "unset"},
+	}
+}
+
+func TestPushTimestampSkewReject(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	pushNow = func() time.Time { return now }
+	defer func() { pushNow = time.Now }()
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "maxTimestampSkew": 60}`, 1))
+
+	before := timestampRejected(t, timestampSkewReject)
+	kept, err := checkTimestamps(skewed(now.Unix()))
+	if err != nil {
+		t.Fatalf("open mode: unexpected error %s", err)
+	}
+	var names []string
+	for _, v := range kept {
+		names = append(names, v.Metric)
+	}
+	if got := strings.Join(names, ","); got != "past,future,unset" {
+		t.Errorf("expected the metrics within the skew kept, got %s", got)
+	}
+	if d := timestampRejected(t, timestampSkewReject) - before; d != 2 {
+		t.Errorf("expected 2 rejected metrics counted, got %v", d)
+	}
+
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "maxTimestampSkew": 60, "validationMode": "closed"}`, 1))
+	if _, err := checkTimestamps(skewed(now.Unix())); err == nil {
+		t.Error("closed mode: expected the batch to be rejected")
+	}
+	body := `[{"metric":"m","value":1,"counterType":"GAUGE","timestamp":1499999000}]`
+	if rec := post("/v1/push", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("closed mode: expected 400, got %d", rec.Code)
+	}
+	body = `{"metric":"m","timestamp":1499999000,"value":1,"tags":{"host":"web01"}}`
+	if rec := post("/v1/push/opentsdb", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("closed mode: expected 400 for opentsdb, got %d", rec.Code)
+	}
+}
+
+func TestPushTimestampSkewClamp(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	pushNow = func() time.Time { return now }
+	defer func() { pushNow = time.Now }()
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "maxTimestampSkew": 60, "timestampSkewAction": "clamp"}`, 1))
+
+	before := timestampRejected(t, timestampSkewClamp)
+	kept, err := checkTimestamps(skewed(now.Unix()))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := map[string]int64{
+		"past":   now.Unix() - 60,
+		"future": now.Unix() + 60,
+		"tooold": now.Unix() - 60,
+		"toonew": now.Unix() + 60,
+		"unset":  0,
+	}
+	if len(kept) != len(want) {
+		t.Fatalf("expected all %d metrics kept, got %d", len(want), len(kept))
+	}
+	for _, v := range kept {
+		if v.Timestamp != want[v.Metric] {
+			t.Errorf("%s: expected timestamp %d, got %d", v.Metric, want[v.Metric], v.Timestamp)
+		}
+	}
+	if d := timestampRejected(t, timestampSkewClamp) - before; d != 2 {
+		t.Errorf("expected 2 clamped metrics counted, got %v", d)
+	}
+}
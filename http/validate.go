@@ -0,0 +1,74 @@
+package http
+
+import (
+	"github.com/open-falcon/common/model"
+	"net/http"
+)
+
+// metricReport is the outcome of validating the metric at Index of a batch,
+// with the problems found per field.
+type metricReport struct {
+	Index  int               `json:"index"`
+	Valid  bool              `json:"valid"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type validationReport struct {
+	Valid   bool            `json:"valid"`
+	Metrics []*metricReport `json:"metrics"`
+}
+
+// reportMetrics runs every check of validateMetric on each metric, rather
+// than stopping at the first problem, and reports timestamps beyond
+// http.maxTimestampSkew as checkTimestamps would.
+func reportMetrics(metrics []*model.MetricValue) validationReport {
+	bounds, checkSkew := currentTimestampBounds()
+	report := validationReport{Valid: true, Metrics: make([]*metricReport, len(metrics))}
+	for i, v := range metrics {
+		r := &metricReport{Index: i, Valid: true}
+		if v == nil {
+			r.Errors = map[string]string{"metric": "metric is null"}
+		} else {
+			for _, c := range metricChecks {
+				if err := c.check(v); err != nil {
+					if r.Errors == nil {
+						r.Errors = map[string]string{}
+					}
+					r.Errors[c.field] = err.Error()
+				}
+			}
+			if _, ok := r.Errors["timestamp"]; !ok && checkSkew {
+				if err := bounds.check(v.Timestamp); err != nil {
+					if r.Errors == nil {
+						r.Errors = map[string]string{}
+					}
+					r.Errors["timestamp"] = err.Error()
+				}
+			}
+		}
+		if len(r.Errors) > 0 {
+			r.Valid, report.Valid = false, false
+		}
+		report.Metrics[i] = r
+	}
+	return report
+}
+
+// configValidateRoutes registers /v1/validate, which reports the problems of
+// a push body without completing, counting or forwarding its metrics, for
+// checking clients in CI.
+func configValidateRoutes() {
+	http.HandleFunc("/v1/validate", func(w http.ResponseWriter, req *http.Request) {
+		bs, err := readPushBody(w, req)
+		if err != nil {
+			http.Error(w, err.Error(), pushBodyStatus(err))
+			return
+		}
+		var metrics []*model.MetricValue
+		if err := decodePushJson(bs, &metrics); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		RenderJson(w, reportMetrics(metrics))
+	})
+}
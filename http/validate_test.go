@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateReport(t *testing.T) {
+	setConfig(t, testConfig)
+	body := `[
+		{"endpoint":"host","metric":"ok","value":1,"counterType":"GAUGE"},
+		{"endpoint":"host","metric":"","value":1,"counterType":"GAUGE","tags":"broken"},
+		{"endpoint":"host","metric":"m","value":1,"counterType":"HISTOGRAM","timestamp":-1},
+		null
+	]`
+	rec := post("/v1/validate", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var report validationReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding %s failed: %s", rec.Body, err)
+	}
+	if report.Valid || len(report.Metrics) != 4 {
+		t.Fatalf("expected an invalid report of 4 metrics, got %s", rec.Body)
+	}
+	for i, fields := range [][]string{nil, {"metric", "tags"}, {"counterType", "timestamp"}, {"metric"}} {
+		r := report.Metrics[i]
+		var got []string
+		for _, c := range metricChecks {
+			if _, ok := r.Errors[c.field]; ok {
+				got = append(got, c.field)
+			}
+		}
+		if !reflect.DeepEqual(got, fields) || r.Valid != (fields == nil) {
+			t.Errorf("metric %d: expected problems with %v, got %v", i, fields, r.Errors)
+		}
+	}
+}
+
+func TestValidateTimestampSkew(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	pushNow = func() time.Time { return now }
+	defer func() { pushNow = time.Now }()
+	setConfig(t, strings.Replace(testConfig, `"enabled": false}`, `"enabled": false, "maxTimestampSkew": 60}`, 1))
+
+	body := `[
+		{"endpoint":"host","metric":"m","value":1,"counterType":"GAUGE","timestamp":1500000030},
+		{"endpoint":"host","metric":"m","value":1,"counterType":"GAUGE","timestamp":1499999000},
+		{"endpoint":"host","metric":"m","value":1,"counterType":"GAUGE"}
+	]`
+	var report validationReport
+	if err := json.Unmarshal(post("/v1/validate", body).Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.Metrics) != 3 {
+		t.Fatalf("expected an invalid report of 3 metrics, got %v", report)
+	}
+	for i, valid := range []bool{true, false, true} {
+		if r := report.Metrics[i]; r.Valid != valid || (!valid && r.Errors["timestamp"] == "") {
+			t.Errorf("metric %d: expected valid %v, got %v", i, valid, r.Errors)
+		}
+	}
+}
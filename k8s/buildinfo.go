@@ -0,0 +1,43 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Version and Revision identify the build and are set with -ldflags, e.g.
+// -X github.com/domeos/agent/k8s.Revision=$(git rev-parse --short HEAD).
+var (
+	Version  = "unknown"
+	Revision = "unknown"
+)
+
+// newBuildInfo returns the constant agent_build_info gauge.
+func newBuildInfo() prometheus.Collector {
+	info := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_build_info",
+			Help: "A metric with a constant '1' value labeled by version, revision and Go version of the agent.",
+		},
+		[]string{"version", "revision", "go_version"},
+	)
+	info.WithLabelValues(Version, Revision, runtime.Version()).Set(1)
+	return info
+}
@@ -0,0 +1,20 @@
+package k8s
+
+import "testing"
+
+func TestBuildInfo(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+	Version = "1.2.3"
+
+	mfs := gatherFrom(t, newBuildInfo())
+	if len(mfs) != 1 || mfs[0].GetName() != "agent_build_info" {
+		t.Fatalf("expected agent_build_info, got %v", mfs)
+	}
+	m := mfs[0].GetMetric()[0]
+	if v := labelValue(m, "version"); v != "1.2.3" {
+		t.Errorf("expected injected version, got %q", v)
+	}
+	if m.GetGauge().GetValue() != 1 {
+		t.Errorf("expected value 1, got %v", m.GetGauge().GetValue())
+	}
+}
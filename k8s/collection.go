@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectionMetrics are the self metrics of a metric collection. They are
+// created per collection, so that collections registered with separate
+// registries, e.g. one per cluster, do not share series.
+type collectionMetrics struct {
+	informerLastSync    *prometheus.GaugeVec
+	informerRebuilds    *prometheus.CounterVec
+	informerEvents      *prometheus.CounterVec
+	collectorDegraded   *prometheus.GaugeVec
+	collectorRegistered *prometheus.GaugeVec
+	workloadScaleEvents *prometheus.CounterVec
+
+	// listWatchErrors is the time of the last failed list or watch call.
+	listWatchErrors *errorTime
+}
+
+func newCollectionMetrics() *collectionMetrics {
+	return &collectionMetrics{
+		informerLastSync:    newInformerLastSync(),
+		informerRebuilds:    newInformerRebuilds(),
+		informerEvents:      newInformerEvents(),
+		collectorDegraded:   newCollectorDegraded(),
+		collectorRegistered: newCollectorRegistered(),
+		workloadScaleEvents: newWorkloadScaleEvents(),
+		listWatchErrors:     &errorTime{},
+	}
+}
+
+// collectors returns the vectors to register with the registry of the
+// collection.
+func (m *collectionMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.informerLastSync,
+		m.informerRebuilds,
+		m.informerEvents,
+		m.collectorDegraded,
+		m.collectorRegistered,
+		m.workloadScaleEvents,
+	}
+}
+
+// degradation returns the degradation of collector, reported in the metrics
+// of the collection.
+func (m *collectionMetrics) degradation(collector string) *degradation {
+	return newDegradation(collector, m.collectorDegraded, m.listWatchErrors)
+}
+
+// MetricCollection is a running metric collection, as returned by
+// InitializeMetricCollectionFor.
+type MetricCollection struct {
+	reg       *trackingRegisterer
+	metrics   *collectionMetrics
+	informers map[string]informerState
+	// handlers are the routes of the collection by pattern, e.g. the admin
+	// endpoints.
+	handlers map[string]http.Handler
+}
+
+// Synced reports whether all informers of c have synced.
+func (c *MetricCollection) Synced() bool {
+	for _, inf := range c.informers {
+		if !inf.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler returns the routes of the metrics server for c, serving the
+// metrics of gatherer, which gathers the registry c was initialized for:
+//
+//	reg := prometheus.NewRegistry()
+//	c := k8s.InitializeMetricCollectionFor(kubeClient, reg)
+//	http.ListenAndServe(":8081", c.Handler(reg))
+func (c *MetricCollection) Handler(gatherer prometheus.Gatherer) http.Handler {
+	metrics := metricsHandler(*instrumentMetricsHandler, c.reg, gatherer)
+	if *metricsRequireSync {
+		metrics = requireSynced(c.Synced, metrics)
+	}
+	patterns := make([]string, 0, len(c.handlers))
+	for pattern := range c.handlers {
+		patterns = append(patterns, pattern)
+	}
+	return buildMetricsMux(normalizeRoutePrefix(*routePrefix), metrics, patterns, func(pattern string) http.Handler {
+		return c.handlers[pattern]
+	})
+}
+
+// publish makes c the collection served by the metrics server of the agent,
+// replacing the one published before.
+func (c *MetricCollection) publish() {
+	setRunningInformers(c.informers)
+	for pattern, handler := range c.handlers {
+		if err := RegisterHandler(pattern, handler); err != nil {
+			glog.Errorf("publishing the metric collection: %s", err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorsPath switches collectors at
+// collectorsPath{name}/{enable|disable}.
+const collectorsPath = "/admin/collectors/"
+
+func newCollectorRegistered() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_collector_registered",
+			Help: "Whether a collector is registered, or 0 if it failed to register, was skipped or is disabled.",
+		},
+		[]string{"collector"},
+	)
+}
+
+// collectorSwitch registers named collectors and lets operators unregister
+// and register them again at runtime, e.g. to shed load during incidents.
+type collectorSwitch struct {
+	reg prometheus.Registerer
+	// registered is set per collector to whether it is registered.
+	registered *prometheus.GaugeVec
+
+	mu         sync.Mutex
+	collectors map[string]prometheus.Collector
+}
+
+func newCollectorSwitch(reg prometheus.Registerer, registered *prometheus.GaugeVec) *collectorSwitch {
+	return &collectorSwitch{reg: reg, registered: registered, collectors: map[string]prometheus.Collector{}}
+}
+
+// add registers c under name.
+func (s *collectorSwitch) add(name string, c prometheus.Collector) {
+	ok := true
+	if err := s.reg.Register(c); err != nil {
+		if _, ok = err.(prometheus.AlreadyRegisteredError); ok {
+			glog.Warningf("collector %s already registered, keeping the registered one", name)
+		} else {
+			glog.Errorf("registering collector %s failed: %s", name, err)
+		}
+	}
+	s.registered.WithLabelValues(name).Set(boolFloat64(ok))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collectors[name] = c
+}
+
+// skip records that the collector name was not registered, e.g. because its
+// API group is not served.
+func (s *collectorSwitch) skip(name string) {
+	s.registered.WithLabelValues(name).Set(0)
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *collectorSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, collectorsPath), "/")
+	if len(parts) != 2 {
+		http.Error(w, "expected "+collectorsPath+"{name}/{enable|disable}", http.StatusBadRequest)
+		return
+	}
+	name, action := parts[0], parts[1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.collectors[name]
+	if !ok {
+		http.Error(w, "unknown collector "+name, http.StatusNotFound)
+		return
+	}
+	switch action {
+	case "enable":
+		if err := s.reg.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.registered.WithLabelValues(name).Set(1)
+	case "disable":
+		s.reg.Unregister(c)
+		s.registered.WithLabelValues(name).Set(0)
+	default:
+		http.Error(w, "unknown action "+action, http.StatusBadRequest)
+		return
+	}
+	glog.Infof("%sd collector %s", action, name)
+	w.Write([]byte("ok"))
+}
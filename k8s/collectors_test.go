@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestCollectorSwitch(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	registered := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_registered", Help: "help"}, []string{"collector"})
+	s := newCollectorSwitch(reg, registered)
+	pods := []v1.Pod{{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"}}}
+	s.add("pods", &podCollector{store: PodLister(func() ([]v1.Pod, error) { return pods, nil })})
+
+	switchCollector := func(path string) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("POST", collectorsPath+path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d %s", path, rec.Code, rec.Body)
+		}
+	}
+	hasPodInfo := func() bool {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hasMetricFamily(mfs, "kube_pod_info")
+	}
+
+	if !hasPodInfo() {
+		t.Fatal("expected pod series while enabled")
+	}
+	switchCollector("pods/disable")
+	if hasPodInfo() {
+		t.Error("expected pod series to disappear once disabled")
+	}
+	if v := gaugeValue(t, registered, "pods"); v != 0 {
+		t.Errorf("expected a disabled collector to be reported unregistered, got %v", v)
+	}
+	switchCollector("pods/enable")
+	if !hasPodInfo() {
+		t.Error("expected pod series to be back once enabled")
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", collectorsPath+"unknown/disable", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown collector, got %d", rec.Code)
+	}
+}
+
+func TestCollectorSwitchRegistered(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	registered := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_registered", Help: "help"}, []string{"collector"})
+	s := newCollectorSwitch(reg, registered)
+
+	s.add("pods", &podCollector{store: PodLister(func() ([]v1.Pod, error) { return nil, nil })})
+	s.skip("endpointslices")
+
+	if v := gaugeValue(t, registered, "pods"); v != 1 {
+		t.Errorf("expected pods to be registered, got %v", v)
+	}
+	if v := gaugeValue(t, registered, "endpointslices"); v != 0 {
+		t.Errorf("expected the skipped endpointslices to be reported unregistered, got %v", v)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var (
+	descComponentStatusHealthy = newObjectDesc(
+		"kube_componentstatus_healthy",
+		"The health of a control plane component, e.g. etcd or the scheduler.",
+		[]string{"component", "condition"},
+	)
+)
+
+type componentstatusStore interface {
+	List() ([]v1.ComponentStatus, error)
+}
+
+// componentstatusCollector collects the health of the control plane
+// components reported by the componentstatuses API.
+type componentstatusCollector struct {
+	store componentstatusStore
+}
+
+// Describe implements the prometheus.Collector interface.
+func (cc *componentstatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descComponentStatusHealthy)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (cc *componentstatusCollector) Collect(ch chan<- prometheus.Metric) {
+	css, err := cc.store.List()
+	if err != nil {
+		glog.Errorf("listing component statuses failed: %s", err)
+		return
+	}
+	for _, cs := range css {
+		for _, c := range cs.Conditions {
+			if c.Type == v1.ComponentHealthy {
+				addConditionMetrics(ch, descComponentStatusHealthy, c.Status, cs.UID, cs.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/runtime/schema"
+	core "k8s.io/client-go/testing"
+)
+
+func TestComponentStatusCollector(t *testing.T) {
+	css := []v1.ComponentStatus{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "etcd-0"},
+			Conditions: []v1.ComponentCondition{{Type: v1.ComponentHealthy, Status: v1.ConditionTrue}},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "scheduler"},
+			Conditions: []v1.ComponentCondition{{Type: v1.ComponentHealthy, Status: v1.ConditionFalse}},
+		},
+	}
+	cc := &componentstatusCollector{
+		store: ComponentStatusLister(func() ([]v1.ComponentStatus, error) { return css, nil }),
+	}
+
+	want := map[string]string{"etcd-0": "true", "scheduler": "false"}
+	mfs := gatherFrom(t, cc)
+	if len(mfs) != 1 {
+		t.Fatalf("expected one metric family, got %d", len(mfs))
+	}
+	for _, m := range mfs[0].GetMetric() {
+		component, condition := labelValue(m, "component"), labelValue(m, "condition")
+		if v := m.GetGauge().GetValue(); (condition == want[component]) != (v == 1) {
+			t.Errorf("%s condition=%s: unexpected value %v", component, condition, v)
+		}
+	}
+}
+
+func TestPollComponentStatusesStopsOnNotFound(t *testing.T) {
+	var lists int32
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("list", "componentstatuses", func(core.Action) (bool, runtime.Object, error) {
+		atomic.AddInt32(&lists, 1)
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "componentstatuses"}, "")
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	pollComponentStatuses(kubeClient, 10*time.Millisecond, stopCh)
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&lists); n != 1 {
+		t.Errorf("expected polling to stop after the first NotFound, listed %d times", n)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// descCriticalDeploymentAvailable is not an object desc: a pinned deployment
+// that is missing is reported as well, and its series must not change when
+// the deployment is recreated.
+var descCriticalDeploymentAvailable = newDesc(
+	"kube_critical_deployment_available",
+	"Whether all desired replicas of a deployment pinned with --critical-deployments are available; 0 if it does not exist.",
+	[]string{"namespace", "deployment"},
+)
+
+// parseCriticalDeployments parses namespace/name pairs into the keys of the
+// deployments pinned by criticalDeploymentCollector.
+func parseCriticalDeployments(ss []string) (map[string]bool, error) {
+	pinned := map[string]bool{}
+	for _, s := range ss {
+		parts := strings.Split(s, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("deployment %q is not of the form namespace/name", s)
+		}
+		pinned[s] = true
+	}
+	return pinned, nil
+}
+
+// criticalDeploymentCollector reports the availability of a fixed set of
+// deployments, such as kube-system/kube-dns, that the cluster cannot do
+// without. Unlike the deployment metrics its series exist whether or not the
+// deployment does, so alerts also fire when it is deleted. The namespace
+// filter does not apply to pinned deployments.
+type criticalDeploymentCollector struct {
+	store deploymentStore
+	// pinned holds the namespace/name of the critical deployments.
+	pinned map[string]bool
+}
+
+// Describe implements the prometheus.Collector interface.
+func (cc *criticalDeploymentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descCriticalDeploymentAvailable)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (cc *criticalDeploymentCollector) Collect(ch chan<- prometheus.Metric) {
+	if len(cc.pinned) == 0 {
+		return
+	}
+	dpls, err := cc.store.List()
+	if err != nil {
+		glog.Errorf("listing deployments failed: %s", err)
+		return
+	}
+	available := map[string]bool{}
+	for _, d := range dpls {
+		key := d.Namespace + "/" + d.Name
+		if cc.pinned[key] {
+			available[key] = deploymentAvailable(d)
+		}
+	}
+	keys := make([]string, 0, len(cc.pinned))
+	for key := range cc.pinned {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts := strings.SplitN(key, "/", 2)
+		ch <- mustNewConstMetric(objectDesc(descCriticalDeploymentAvailable), prometheus.GaugeValue, boolFloat64(available[key]), parts[0], parts[1])
+	}
+}
+
+// deploymentAvailable reports whether at least one and all desired replicas
+// of d are available. A critical deployment scaled to zero is not.
+func deploymentAvailable(d v1beta1.Deployment) bool {
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	return d.Status.AvailableReplicas > 0 && d.Status.AvailableReplicas >= desired
+}
@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestCriticalDeploymentAvailable(t *testing.T) {
+	replicas := int32(2)
+	deployment := func(namespace, name string, available int32) v1beta1.Deployment {
+		return v1beta1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+			Status:     v1beta1.DeploymentStatus{AvailableReplicas: available},
+		}
+	}
+	dpls := []v1beta1.Deployment{
+		deployment("kube-system", "kube-dns", 2),
+		deployment("kube-system", "heapster", 1),
+		deployment("default", "web", 2),
+	}
+	pinned, err := parseCriticalDeployments([]string{"kube-system/kube-dns", "kube-system/heapster", "kube-system/coredns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &criticalDeploymentCollector{
+		store:  DeploymentLister(func() ([]v1beta1.Deployment, error) { return dpls, nil }),
+		pinned: pinned,
+	}
+
+	got := map[string]float64{}
+	for _, mf := range gatherFrom(t, cc) {
+		if mf.GetName() != "kube_critical_deployment_available" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			got[labelValue(m, "namespace")+"/"+labelValue(m, "deployment")] = m.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{"kube-system/kube-dns": 1, "kube-system/heapster": 0, "kube-system/coredns": 0}
+	if len(got) != len(want) {
+		t.Errorf("expected only the pinned deployments, got %v", got)
+	}
+	for key, w := range want {
+		if v, ok := got[key]; !ok || v != w {
+			t.Errorf("%s: expected %v, got %v (present %t)", key, w, v, ok)
+		}
+	}
+
+	if _, err := parseCriticalDeployments([]string{"kube-dns"}); err == nil {
+		t.Error("expected an error for a deployment without namespace")
+	}
+}
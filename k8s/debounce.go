@@ -0,0 +1,119 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// debouncer coalesces bursts of store change notifications, e.g. during a
+// rollout, so that an expensive rebuild runs at most once per window.
+type debouncer struct {
+	window time.Duration
+	fn     func()
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// newDebouncer returns a debouncer calling fn once window has passed after
+// the first of a burst of triggers. A window <= 0 disables coalescing.
+func newDebouncer(window time.Duration, fn func()) *debouncer {
+	return &debouncer{window: window, fn: fn}
+}
+
+// trigger schedules a call to fn. Triggers arriving while a call is pending
+// are folded into it.
+func (d *debouncer) trigger() {
+	if d.window <= 0 {
+		d.fn()
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		return
+	}
+	d.timer = time.AfterFunc(d.window, func() {
+		d.mu.Lock()
+		d.timer = nil
+		d.mu.Unlock()
+		d.fn()
+	})
+}
+
+// snapshotCollector serves the metrics of a collector from a snapshot that is
+// rebuilt, debounced by window, when the informers behind it deliver events,
+// instead of walking their stores on every scrape. Until the first rebuild
+// the collector is read directly.
+type snapshotCollector struct {
+	prometheus.Collector
+	debouncer *debouncer
+
+	mu      sync.RWMutex
+	built   bool
+	metrics []prometheus.Metric
+}
+
+func newSnapshotCollector(c prometheus.Collector, window time.Duration) *snapshotCollector {
+	sc := &snapshotCollector{Collector: c}
+	sc.debouncer = newDebouncer(window, sc.rebuild)
+	return sc
+}
+
+// handler returns the event handler scheduling a rebuild on every event.
+func (sc *snapshotCollector) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { sc.debouncer.trigger() },
+		UpdateFunc: func(interface{}, interface{}) { sc.debouncer.trigger() },
+		DeleteFunc: func(interface{}) { sc.debouncer.trigger() },
+	}
+}
+
+func (sc *snapshotCollector) rebuild() {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		sc.Collector.Collect(ch)
+		close(ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.built = true
+	sc.metrics = metrics
+}
+
+// Collect implements the prometheus.Collector interface.
+func (sc *snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if !sc.built {
+		sc.Collector.Collect(ch)
+		return
+	}
+	for _, m := range sc.metrics {
+		ch <- m
+	}
+}
@@ -0,0 +1,64 @@
+package k8s
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestDebouncerCoalesces(t *testing.T) {
+	var rebuilds int32
+	d := newDebouncer(50*time.Millisecond, func() {
+		atomic.AddInt32(&rebuilds, 1)
+	})
+
+	for i := 0; i < 10; i++ {
+		d.trigger()
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&rebuilds); n != 1 {
+		t.Errorf("expected 1 rebuild, got %d", n)
+	}
+}
+
+func TestSnapshotCollectorRebuildsOnEvents(t *testing.T) {
+	var mu sync.Mutex
+	pods := []v1.Pod{{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "a"}}}
+	sc := newSnapshotCollector(&podCollector{store: PodLister(func() ([]v1.Pod, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return pods, nil
+	})}, 50*time.Millisecond)
+	podNames := func() map[string]bool {
+		names := map[string]bool{}
+		for _, mf := range gatherFrom(t, sc) {
+			for _, m := range mf.GetMetric() {
+				names[labelValue(m, "pod")] = true
+			}
+		}
+		return names
+	}
+
+	if names := podNames(); !names["a"] {
+		t.Fatalf("expected the store to be read before the first rebuild, got %v", names)
+	}
+	sc.handler().OnAdd(nil)
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	pods = append(pods, v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "b"}})
+	mu.Unlock()
+	if names := podNames(); names["b"] {
+		t.Errorf("expected the snapshot to be served until the next event, got %v", names)
+	}
+
+	sc.handler().OnUpdate(nil, nil)
+	time.Sleep(150 * time.Millisecond)
+	if names := podNames(); !names["a"] || !names["b"] {
+		t.Errorf("expected the snapshot to be rebuilt after the event, got %v", names)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// degradedAfter is the number of consecutive NotFound errors after which the
+// API group of a collector is considered removed.
+const degradedAfter = 5
+
+func newCollectorDegraded() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_collector_degraded",
+			Help: "Whether a collector stopped emitting because its API is no longer served.",
+		},
+		[]string{"collector"},
+	)
+}
+
+// degradation tracks whether the API behind a collector went away, e.g. when
+// a group is removed during a cluster upgrade. Informers keep retrying in
+// that case and the collector would otherwise serve stale data forever.
+type degradation struct {
+	collector string
+	gauge     *prometheus.GaugeVec
+	// errors records the time of other failed calls, for agent_healthy.
+	errors *errorTime
+
+	mu       sync.Mutex
+	notFound int
+}
+
+func newDegradation(collector string, gauge *prometheus.GaugeVec, errors *errorTime) *degradation {
+	gauge.WithLabelValues(collector).Set(0)
+	return &degradation{collector: collector, gauge: gauge, errors: errors}
+}
+
+// observe records the outcome of a list or watch call.
+func (d *degradation) observe(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err != nil && !apierrors.IsNotFound(err) {
+		d.errors.mark(time.Now())
+	}
+	if err != nil && apierrors.IsNotFound(err) {
+		d.notFound++
+		if d.notFound == degradedAfter {
+			glog.Warningf("%s not found %d times in a row, marking collector degraded", d.collector, d.notFound)
+		}
+	} else {
+		d.notFound = 0
+	}
+	d.gauge.WithLabelValues(d.collector).Set(boolFloat64(d.notFound >= degradedAfter))
+}
+
+func (d *degradation) degraded() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.notFound >= degradedAfter
+}
+
+// trackListWatch reports the errors of lw's calls to d.
+func trackListWatch(lw *cache.ListWatch, d *degradation) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			obj, err := lw.ListFunc(options)
+			d.observe(err)
+			return obj, err
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			w, err := lw.WatchFunc(options)
+			d.observe(err)
+			return w, err
+		},
+	}
+}
+
+// degradableCollector emits nothing while its degradation is degraded.
+type degradableCollector struct {
+	prometheus.Collector
+	degradation *degradation
+}
+
+// Collect implements the prometheus.Collector interface.
+func (dc *degradableCollector) Collect(ch chan<- prometheus.Metric) {
+	if dc.degradation.degraded() {
+		return
+	}
+	dc.Collector.Collect(ch)
+}
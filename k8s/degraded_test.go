@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDegradationOnPersistentNotFound(t *testing.T) {
+	gv := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_degraded"}, []string{"collector"})
+	d := newDegradation("pods", gv, &errorTime{})
+
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "")
+	lw := trackListWatch(&cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			return nil, notFound
+		},
+	}, d)
+
+	pods := []v1.Pod{{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"}}}
+	c := &degradableCollector{
+		Collector:   &podCollector{store: PodLister(func() ([]v1.Pod, error) { return pods, nil })},
+		degradation: d,
+	}
+
+	for i := 0; i < degradedAfter-1; i++ {
+		lw.List(v1.ListOptions{})
+	}
+	if d.degraded() || len(gatherFrom(t, c)) == 0 {
+		t.Fatal("collector must keep emitting below the threshold")
+	}
+
+	lw.List(v1.ListOptions{})
+	if !d.degraded() {
+		t.Fatal("expected collector to be degraded")
+	}
+	if mfs := gatherFrom(t, c); len(mfs) != 0 {
+		t.Errorf("degraded collector emitted %d metric families", len(mfs))
+	}
+	if v := gaugeValue(t, gv, "pods"); v != 1 {
+		t.Errorf("expected agent_collector_degraded to be 1, got %v", v)
+	}
+
+	d.observe(nil)
+	if d.degraded() || gaugeValue(t, gv, "pods") != 0 {
+		t.Errorf("expected a successful call to clear degradation")
+	}
+}
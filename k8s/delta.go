@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/tools/cache"
+)
+
+// deltaPath serves the series of the objects changed since its last scrape.
+const deltaPath = "/metrics/delta"
+
+// changeTracker records the keys of the objects an informer saw change.
+type changeTracker struct {
+	mu      sync.Mutex
+	changed map[string]bool
+}
+
+func newChangeTracker() *changeTracker {
+	return &changeTracker{changed: map[string]bool{}}
+}
+
+// handler returns the event handler marking changed objects.
+func (t *changeTracker) handler() cache.ResourceEventHandler {
+	mark := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		t.changed[key] = true
+		t.mu.Unlock()
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: mark,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			mark(newObj)
+		},
+		DeleteFunc: mark,
+	}
+}
+
+// take returns the keys changed since the last call and resets them.
+func (t *changeTracker) take() map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := t.changed
+	t.changed = map[string]bool{}
+	return changed
+}
+
+// changedObjects returns the objects of store with one of the keys in changed.
+// Deleted objects are gone from the store and thus not returned.
+func changedObjects(store cache.Store, changed map[string]bool) []interface{} {
+	var objs []interface{}
+	for key := range changed {
+		if obj, ok, err := store.GetByKey(key); err == nil && ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// deltaHandler serves the metrics of the collectors returned by collectors,
+// which is called once per scrape and expected to return collectors limited
+// to the objects changed since the previous scrape.
+func deltaHandler(collectors func() []prometheus.Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg := prometheus.NewRegistry()
+		for _, c := range collectors() {
+			if err := reg.Register(c); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
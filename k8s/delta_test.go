@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDeltaHandler(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	tracker := newChangeTracker()
+	h := deltaHandler(func() []prometheus.Collector {
+		var pods []v1.Pod
+		for _, m := range changedObjects(store, tracker.take()) {
+			pods = append(pods, *m.(*v1.Pod))
+		}
+		return []prometheus.Collector{&podCollector{store: PodLister(func() ([]v1.Pod, error) { return pods, nil })}}
+	})
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", deltaPath, nil))
+		return rec.Body.String()
+	}
+
+	pod := &v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"}}
+	store.Add(pod)
+	if body := scrape(); body != "" {
+		t.Errorf("expected empty delta without changes, got:\n%s", body)
+	}
+
+	tracker.handler().OnAdd(pod)
+	if body := scrape(); !strings.Contains(body, `kube_pod_info{`) {
+		t.Errorf("expected series of the changed pod, got:\n%s", body)
+	}
+	if body := scrape(); body != "" {
+		t.Errorf("expected empty delta after the change was scraped, got:\n%s", body)
+	}
+}
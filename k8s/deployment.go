@@ -23,71 +23,95 @@ import (
 )
 
 var (
-	descDeploymentStatusReplicas = prometheus.NewDesc(
+	descDeploymentStatusReplicas = newObjectDesc(
 		"kube_deployment_status_replicas",
 		"The number of replicas per deployment.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
-	descDeploymentStatusReplicasAvailable = prometheus.NewDesc(
+	descDeploymentStatusReplicasAvailable = newObjectDesc(
 		"kube_deployment_status_replicas_available",
 		"The number of available replicas per deployment.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
-	descDeploymentStatusReplicasUnavailable = prometheus.NewDesc(
+	descDeploymentStatusReplicasUnavailable = newObjectDesc(
 		"kube_deployment_status_replicas_unavailable",
 		"The number of unavailable replicas per deployment.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
-	descDeploymentStatusReplicasUpdated = prometheus.NewDesc(
+	descDeploymentStatusReplicasUpdated = newObjectDesc(
 		"kube_deployment_status_replicas_updated",
 		"The number of updated replicas per deployment.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
 
-	descDeploymentStatusObservedGeneration = prometheus.NewDesc(
+	descDeploymentAvailabilityRatio = newObjectDesc(
+		"kube_deployment_availability_ratio",
+		"The fraction of the replicas of a deployment that are available; 0 without replicas.",
+		[]string{"namespace", "deployment"},
+	)
+
+	descDeploymentRolloutComplete = newObjectDesc(
+		"kube_deployment_rollout_complete",
+		"Whether the rollout of a deployment finished: all desired replicas are updated and available, and the controller observed the latest generation.",
+		[]string{"namespace", "deployment"},
+	)
+
+	descDeploymentStatusObservedGeneration = newObjectDesc(
 		"kube_deployment_status_observed_generation",
 		"The generation observed by the deployment controller.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
 
-	descDeploymentSpecReplicas = prometheus.NewDesc(
+	descDeploymentSpecReplicas = newObjectDesc(
 		"kube_deployment_spec_replicas",
 		"Number of desired pods for a deployment.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
 
-	descDeploymentSpecPaused = prometheus.NewDesc(
+	descDeploymentSpecPaused = newObjectDesc(
 		"kube_deployment_spec_paused",
 		"Whether the deployment is paused and will not be processed by the deployment controller.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
 
-	descDeploymentMetadataGeneration = prometheus.NewDesc(
+	descDeploymentMetadataGeneration = newObjectDesc(
 		"kube_deployment_metadata_generation",
 		"Sequence number representing a specific generation of the desired state.",
-		[]string{"namespace", "deployment"}, nil,
+		[]string{"namespace", "deployment"},
 	)
 )
 
+// descDuplicateObjectNames is shared by the collectors checking for names
+// reused across namespaces; only deployments do so far.
+var descDuplicateObjectNames = newDesc(
+	"agent_duplicate_object_names",
+	"The number of namespaces with an object of the kind and name, for names used in more than one.",
+	[]string{"kind", "name"},
+)
+
 type deploymentStore interface {
 	List() (deployments []v1beta1.Deployment, err error)
 }
 
 // deploymentCollector collects metrics about all deployments in the cluster.
 type deploymentCollector struct {
-	store deploymentStore
+	store      deploymentStore
+	namespaces namespaceFilter
 }
 
 // Describe implements the prometheus.Collector interface.
 func (dc *deploymentCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- descDeploymentStatusReplicas
-	ch <- descDeploymentStatusReplicasAvailable
-	ch <- descDeploymentStatusReplicasUnavailable
-	ch <- descDeploymentStatusReplicasUpdated
-	ch <- descDeploymentStatusObservedGeneration
-	ch <- descDeploymentSpecPaused
-	ch <- descDeploymentSpecReplicas
-	ch <- descDeploymentMetadataGeneration
+	ch <- objectDesc(descDeploymentStatusReplicas)
+	ch <- objectDesc(descDeploymentStatusReplicasAvailable)
+	ch <- objectDesc(descDeploymentStatusReplicasUnavailable)
+	ch <- objectDesc(descDeploymentStatusReplicasUpdated)
+	ch <- objectDesc(descDeploymentAvailabilityRatio)
+	ch <- objectDesc(descDeploymentRolloutComplete)
+	ch <- objectDesc(descDeploymentStatusObservedGeneration)
+	ch <- objectDesc(descDeploymentSpecPaused)
+	ch <- objectDesc(descDeploymentSpecReplicas)
+	ch <- objectDesc(descDeploymentMetadataGeneration)
+	ch <- objectDesc(descDuplicateObjectNames)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -97,22 +121,46 @@ func (dc *deploymentCollector) Collect(ch chan<- prometheus.Metric) {
 		glog.Errorf("listing deployments failed: %s", err)
 		return
 	}
+	namespaces := map[string]int{}
 	for _, d := range dpls {
+		if !dc.namespaces.allowed(d.Namespace) {
+			continue
+		}
+		namespaces[d.Name]++
 		dc.collectDeployment(ch, d)
 	}
+	for name, n := range namespaces {
+		if n > 1 {
+			ch <- mustNewConstMetric(objectDesc(descDuplicateObjectNames), prometheus.GaugeValue, float64(n), "Deployment", name)
+		}
+	}
 }
 
 func (dc *deploymentCollector) collectDeployment(ch chan<- prometheus.Metric, d v1beta1.Deployment) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
 		lv = append([]string{d.Namespace, d.Name}, lv...)
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+		ch <- mustNewObjectMetric(desc, d.UID, prometheus.GaugeValue, v, lv...)
 	}
 	addGauge(descDeploymentStatusReplicas, float64(d.Status.Replicas))
 	addGauge(descDeploymentStatusReplicasAvailable, float64(d.Status.AvailableReplicas))
 	addGauge(descDeploymentStatusReplicasUnavailable, float64(d.Status.UnavailableReplicas))
 	addGauge(descDeploymentStatusReplicasUpdated, float64(d.Status.UpdatedReplicas))
+	ratio := 0.0
+	if d.Status.Replicas > 0 {
+		ratio = float64(d.Status.AvailableReplicas) / float64(d.Status.Replicas)
+	}
+	addGauge(descDeploymentAvailabilityRatio, ratio)
+	addGauge(descDeploymentRolloutComplete, boolFloat64(rolloutComplete(d)))
 	addGauge(descDeploymentStatusObservedGeneration, float64(d.Status.ObservedGeneration))
 	addGauge(descDeploymentSpecPaused, boolFloat64(d.Spec.Paused))
 	addGauge(descDeploymentSpecReplicas, float64(*d.Spec.Replicas))
 	addGauge(descDeploymentMetadataGeneration, float64(d.ObjectMeta.Generation))
 }
+
+// rolloutComplete reports whether all desired replicas of d run the latest
+// template and are available, as of the latest generation of its spec.
+func rolloutComplete(d v1beta1.Deployment) bool {
+	return d.Status.UpdatedReplicas == *d.Spec.Replicas &&
+		d.Status.AvailableReplicas == *d.Spec.Replicas &&
+		d.Status.ObservedGeneration >= d.Generation
+}
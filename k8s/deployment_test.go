@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestDeploymentAvailabilityRatio(t *testing.T) {
+	replicas := int32(4)
+	dpls := []v1beta1.Deployment{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "rolling"},
+		Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+		Status:     v1beta1.DeploymentStatus{Replicas: 4, AvailableReplicas: 3},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "scaled-down"},
+		Spec:       v1beta1.DeploymentSpec{Replicas: new(int32)},
+	}}
+	dc := &deploymentCollector{store: DeploymentLister(func() ([]v1beta1.Deployment, error) { return dpls, nil })}
+
+	ratios := map[string]float64{}
+	for _, mf := range gatherFrom(t, dc) {
+		if mf.GetName() != "kube_deployment_availability_ratio" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			ratios[labelValue(m, "deployment")] = m.GetGauge().GetValue()
+		}
+	}
+	if r, ok := ratios["rolling"]; !ok || r != 0.75 {
+		t.Errorf("expected 0.75 for 3 of 4 available, got %v", r)
+	}
+	if r, ok := ratios["scaled-down"]; !ok || r != 0 {
+		t.Errorf("expected 0 without replicas, got %v", r)
+	}
+}
+
+func TestDeploymentRolloutComplete(t *testing.T) {
+	replicas := int32(3)
+	dpls := []v1beta1.Deployment{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "in-progress", Generation: 2},
+		Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+		Status:     v1beta1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1, AvailableReplicas: 3},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "unobserved", Generation: 3},
+		Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+		Status:     v1beta1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "complete", Generation: 2},
+		Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+		Status:     v1beta1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+	}}
+	dc := &deploymentCollector{store: DeploymentLister(func() ([]v1beta1.Deployment, error) { return dpls, nil })}
+
+	complete := map[string]float64{}
+	for _, mf := range gatherFrom(t, dc) {
+		if mf.GetName() != "kube_deployment_rollout_complete" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			complete[labelValue(m, "deployment")] = m.GetGauge().GetValue()
+		}
+	}
+	for name, want := range map[string]float64{"in-progress": 0, "unobserved": 0, "complete": 1} {
+		if v, ok := complete[name]; !ok || v != want {
+			t.Errorf("%s: expected rollout complete %v, got %v", name, want, v)
+		}
+	}
+}
+
+func TestDeploymentDuplicateNames(t *testing.T) {
+	replicas := int32(1)
+	deployment := func(namespace, name string) v1beta1.Deployment {
+		return v1beta1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       v1beta1.DeploymentSpec{Replicas: &replicas},
+		}
+	}
+	dpls := []v1beta1.Deployment{deployment("team-a", "web"), deployment("team-b", "web"), deployment("team-a", "worker")}
+	dc := &deploymentCollector{store: DeploymentLister(func() ([]v1beta1.Deployment, error) { return dpls, nil })}
+
+	for _, mf := range gatherFrom(t, dc) {
+		if mf.GetName() != "agent_duplicate_object_names" {
+			continue
+		}
+		if len(mf.GetMetric()) != 1 {
+			t.Fatalf("expected only web to be reported, got %v", mf.GetMetric())
+		}
+		m := mf.GetMetric()[0]
+		if labelValue(m, "kind") != "Deployment" || labelValue(m, "name") != "web" || m.GetGauge().GetValue() != 2 {
+			t.Errorf("expected web in 2 namespaces, got %v", m)
+		}
+		return
+	}
+	t.Error("no duplicate names reported")
+}
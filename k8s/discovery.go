@@ -0,0 +1,76 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/discovery"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// discoveryClientset is a clientset whose discovery calls go through a
+// separate client, so that they can use narrower credentials than the
+// watches.
+type discoveryClientset struct {
+	clientset.Interface
+	discovery discovery.DiscoveryInterface
+}
+
+// Discovery implements the clientset.Interface interface.
+func (c *discoveryClientset) Discovery() discovery.DiscoveryInterface {
+	return c.discovery
+}
+
+// withDiscoveryConfig returns c with its discovery client built from the
+// kubeconfig at path, against apiServer if not empty. An empty path
+// returns c.
+func withDiscoveryConfig(c clientset.Interface, apiServer, path string) (clientset.Interface, error) {
+	if path == "" {
+		return c, nil
+	}
+	config, err := clientcmd.BuildConfigFromFlags(apiServer, path)
+	if err != nil {
+		return nil, err
+	}
+	config.UserAgent = *userAgent
+	setInsecureSkipTLSVerify(config, *insecureSkipTLSVerify)
+	d, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &discoveryClientset{Interface: c, discovery: d}, nil
+}
+
+// servedGroupVersion returns the first of groupVersions for which the server
+// advertises resource, so that a collector can follow API migrations while
+// emitting the same metric names.
+func servedGroupVersion(d discovery.ServerResourcesInterface, resource string, groupVersions []string) (string, error) {
+	for _, gv := range groupVersions {
+		rl, err := d.ServerResourcesForGroupVersion(gv)
+		if err != nil || rl == nil {
+			continue
+		}
+		for _, r := range rl.APIResources {
+			if r.Name == resource {
+				return gv, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("server does not serve %s in any of %v", resource, groupVersions)
+}
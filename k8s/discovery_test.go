@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"k8s.io/client-go/discovery/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/unversioned"
+	core "k8s.io/client-go/testing"
+)
+
+func fakeDiscovery(groupVersions ...string) *fake.FakeDiscovery {
+	f := &fake.FakeDiscovery{Fake: &core.Fake{}}
+	f.Resources = map[string]*unversioned.APIResourceList{}
+	for _, gv := range groupVersions {
+		f.Resources[gv] = &unversioned.APIResourceList{
+			GroupVersion: gv,
+			APIResources: []unversioned.APIResource{{Name: "ingresses", Namespaced: true, Kind: "Ingress"}},
+		}
+	}
+	return f
+}
+
+func TestServedIngressGroupVersion(t *testing.T) {
+	cases := []struct {
+		served []string
+		want   string
+	}{
+		{[]string{"networking.k8s.io/v1"}, "networking.k8s.io/v1"},
+		{[]string{"extensions/v1beta1"}, "extensions/v1beta1"},
+		{[]string{"extensions/v1beta1", "networking.k8s.io/v1"}, "networking.k8s.io/v1"},
+	}
+	for _, c := range cases {
+		gv, err := servedGroupVersion(fakeDiscovery(c.served...), "ingresses", ingressGroupVersions)
+		if err != nil {
+			t.Fatalf("served %v: %s", c.served, err)
+		}
+		if gv != c.want {
+			t.Errorf("served %v: expected %s, got %s", c.served, c.want, gv)
+		}
+	}
+
+	if _, err := servedGroupVersion(fakeDiscovery(), "ingresses", ingressGroupVersions); err == nil {
+		t.Errorf("expected error when no ingress version is served")
+	}
+}
+
+func TestWithDiscoveryConfig(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major": "1", "minor": "5", "gitVersion": "v1.5.0"}`))
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "discovery-kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, `apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster: {server: %q}
+users:
+- name: discovery
+  user: {token: discovery-only}
+contexts:
+- name: c
+  context: {cluster: c, user: discovery}
+current-context: c
+`, srv.URL)
+	f.Close()
+
+	watchClient := kubefake.NewSimpleClientset()
+	if c, err := withDiscoveryConfig(watchClient, "", ""); err != nil || c != watchClient {
+		t.Errorf("expected the watch client without a discovery config, got %v, %v", c, err)
+	}
+
+	c, err := withDiscoveryConfig(watchClient, "", f.Name())
+	if err != nil {
+		t.Fatalf("creating discovery client failed: %s", err)
+	}
+	v, err := c.Discovery().ServerVersion()
+	if err != nil {
+		t.Fatalf("getting server version failed: %s", err)
+	}
+	if v.GitVersion != "v1.5.0" || <-paths != "/version" {
+		t.Errorf("expected the version of the discovery server, got %v", v)
+	}
+}
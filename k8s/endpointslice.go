@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const serviceNameLabel = "kubernetes.io/service-name"
+
+// endpointSliceGroupVersions lists the group versions serving endpoint slices,
+// most preferred first.
+var endpointSliceGroupVersions = []string{
+	"discovery.k8s.io/v1",
+	"discovery.k8s.io/v1beta1",
+}
+
+var (
+	descEndpointSliceEndpointsReady = newDesc(
+		"kube_endpointslice_endpoints_ready",
+		"The number of ready endpoints per service across its endpoint slices.",
+		[]string{"namespace", "service"},
+	)
+	descEndpointSliceEndpointsNotReady = newDesc(
+		"kube_endpointslice_endpoints_not_ready",
+		"The number of not ready endpoints per service across its endpoint slices.",
+		[]string{"namespace", "service"},
+	)
+)
+
+// endpointSlice holds the fields of a discovery.k8s.io EndpointSlice the
+// collector needs. The vendored client-go predates the API, so slices are
+// decoded from the raw list response.
+type endpointSlice struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Endpoints     []endpointSliceEndpoint `json:"endpoints"`
+}
+
+type endpointSliceEndpoint struct {
+	Conditions struct {
+		// Ready is nil when the state is unknown, which consumers should
+		// interpret as ready.
+		Ready *bool `json:"ready"`
+	} `json:"conditions"`
+}
+
+type endpointSliceList struct {
+	Items []endpointSlice `json:"items"`
+}
+
+type endpointsliceStore interface {
+	List() ([]endpointSlice, error)
+}
+
+// endpointsliceCollector collects endpoint readiness per service from all
+// endpoint slices in the cluster.
+type endpointsliceCollector struct {
+	store      endpointsliceStore
+	namespaces namespaceFilter
+}
+
+// Describe implements the prometheus.Collector interface.
+func (ec *endpointsliceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descEndpointSliceEndpointsReady)
+	ch <- objectDesc(descEndpointSliceEndpointsNotReady)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (ec *endpointsliceCollector) Collect(ch chan<- prometheus.Metric) {
+	slices, err := ec.store.List()
+	if err != nil {
+		glog.Errorf("listing endpoint slices failed: %s", err)
+		return
+	}
+
+	type service struct{ namespace, name string }
+	type readiness struct{ ready, notReady int }
+	counts := map[service]*readiness{}
+	for _, s := range slices {
+		if !ec.namespaces.allowed(s.Namespace) {
+			continue
+		}
+		name, ok := s.Labels[serviceNameLabel]
+		if !ok {
+			continue
+		}
+		svc := service{s.Namespace, name}
+		c, ok := counts[svc]
+		if !ok {
+			c = &readiness{}
+			counts[svc] = c
+		}
+		for _, e := range s.Endpoints {
+			if e.Conditions.Ready == nil || *e.Conditions.Ready {
+				c.ready++
+			} else {
+				c.notReady++
+			}
+		}
+	}
+
+	for svc, c := range counts {
+		ch <- mustNewConstMetric(objectDesc(descEndpointSliceEndpointsReady), prometheus.GaugeValue, float64(c.ready), svc.namespace, svc.name)
+		ch <- mustNewConstMetric(objectDesc(descEndpointSliceEndpointsNotReady), prometheus.GaugeValue, float64(c.notReady), svc.namespace, svc.name)
+	}
+}
@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestEndpointSliceCollector(t *testing.T) {
+	ready, notReady := true, false
+	slice := endpointSlice{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "web-abc",
+			Labels:    map[string]string{serviceNameLabel: "web"},
+		},
+	}
+	for _, r := range []*bool{&ready, &ready, &notReady, nil} {
+		e := endpointSliceEndpoint{}
+		e.Conditions.Ready = r
+		slice.Endpoints = append(slice.Endpoints, e)
+	}
+	ec := &endpointsliceCollector{
+		store: EndpointSliceLister(func() ([]endpointSlice, error) { return []endpointSlice{slice}, nil }),
+	}
+
+	want := map[string]float64{
+		"kube_endpointslice_endpoints_ready":     3,
+		"kube_endpointslice_endpoints_not_ready": 1,
+	}
+	for _, mf := range gatherFrom(t, ec) {
+		m := mf.GetMetric()[0]
+		if labelValue(m, "service") != "web" || m.GetGauge().GetValue() != want[mf.GetName()] {
+			t.Errorf("%s: unexpected series %v", mf.GetName(), m)
+		}
+		delete(want, mf.GetName())
+	}
+	if len(want) != 0 {
+		t.Errorf("missing metrics %v", want)
+	}
+}
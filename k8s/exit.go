@@ -0,0 +1,76 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/golang/glog"
+)
+
+// exitReason is why the agent stops, with the exit code telling the common
+// failures apart for orchestrators restarting it.
+type exitReason struct {
+	code int
+	name string
+}
+
+var (
+	exitServerError          = exitReason{1, "server_error"}
+	exitConfigError          = exitReason{2, "config_error"}
+	exitAPIServerUnreachable = exitReason{3, "apiserver_unreachable"}
+	exitBindFailure          = exitReason{4, "bind_failure"}
+)
+
+// unreachableError is returned by CreateKubeClient when the apiserver does
+// not answer the version check.
+type unreachableError struct{ error }
+
+// bindError is returned by listenAndServe when the listen address cannot be
+// bound, e.g. because it is in use.
+type bindError struct{ error }
+
+// exitReasonOf returns the reason to stop for an error of the metrics
+// servers or the client setup.
+func exitReasonOf(err error) exitReason {
+	switch err.(type) {
+	case bindError:
+		return exitBindFailure
+	case unreachableError:
+		return exitAPIServerUnreachable
+	}
+	return exitServerError
+}
+
+// shutdown logs the reason the agent stops in a fixed key=value form and
+// returns its exit code.
+func shutdown(reason exitReason, err error) int {
+	glog.Errorf("shutting down: reason=%s exit_code=%d error=%q", reason.name, reason.code, err)
+	glog.Flush()
+	return reason.code
+}
+
+// listenAndServe is srv.ListenAndServe, except that failing to bind the
+// address is reported as a bindError.
+func listenAndServe(srv *http.Server) error {
+	l, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return bindError{err}
+	}
+	return srv.Serve(l)
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestBindFailureExitCode(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	err = listenAndServe(newMetricsServer(l.Addr().String(), http.NotFoundHandler()))
+	if _, ok := err.(bindError); !ok {
+		t.Fatalf("expected a bind error for an address in use, got %v", err)
+	}
+	if code := shutdown(exitReasonOf(err), err); code != 4 {
+		t.Errorf("expected exit code 4 for a bind failure, got %d", code)
+	}
+}
+
+func TestExitReasonOf(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want exitReason
+	}{
+		{bindError{errors.New("address in use")}, exitBindFailure},
+		{unreachableError{errors.New("timed out")}, exitAPIServerUnreachable},
+		{errors.New("accept failed"), exitServerError},
+	} {
+		if got := exitReasonOf(tc.err); got != tc.want {
+			t.Errorf("%v: expected %s, got %s", tc.err, tc.want.name, got.name)
+		}
+	}
+}
+
+func TestInvalidCriticalDeploymentsExitCode(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+	defer func(v []string) { *criticalDeployments = v }(*criticalDeployments)
+	defer func(v string) { *apiserver = v }(*apiserver)
+	os.Args = []string{"agent", "--apiserver=http://127.0.0.1:1", "--critical-deployments=kube-dns"}
+
+	if code := run(); code != exitConfigError.code {
+		t.Errorf("expected exit code %d for an invalid --critical-deployments, got %d", exitConfigError.code, code)
+	}
+}
+
+func TestInvalidOwnerExitCode(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+	defer func(v string) { *owner = v }(*owner)
+	defer func(v string) { *apiserver = v }(*apiserver)
+	defer func(v *ownerRef) { ownerTarget = v }(ownerTarget)
+	os.Args = []string{"agent", "--apiserver=http://127.0.0.1:1", "--owner=Deployment/web"}
+
+	if code := run(); code != exitConfigError.code {
+		t.Errorf("expected exit code %d for an invalid --owner, got %d", exitConfigError.code, code)
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricsHandler returns the handler serving metricsPath, the promhttp
+// handler for gatherer. If instrumented is set its scrapes are counted in reg.
+//
+// A namespace query parameter limits the response to the series with that
+// namespace label, for multi-tenant scrapers.
+//
+// All of these handlers negotiate the exposition format through expfmt:
+// scrapers accepting application/vnd.google.protobuf with the delimited
+// encoding get protobuf, everyone else the text format.
+func metricsHandler(instrumented bool, reg prometheus.Registerer, gatherer prometheus.Gatherer) http.Handler {
+	if len(relabelConfigs) > 0 {
+		gatherer = relabelGatherer{gatherer, relabelConfigs}
+	}
+	handler := namespaceScoped(gatherer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	if !instrumented {
+		return handler
+	}
+	return instrumentMetricHandler(reg, handler)
+}
+
+// basicAuth requires the HTTP basic auth credentials user and password for
+// handler. They are compared in constant time.
+func basicAuth(user, password string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requireBasicAuth requires the credentials of --metrics-basic-auth-user for
+// every route of handler but healthzPath, which probes call without them.
+func requireBasicAuth(handler http.Handler) http.Handler {
+	if *metricsBasicAuthUser == "" {
+		return handler
+	}
+	authenticated := basicAuth(*metricsBasicAuthUser, *metricsBasicAuthPassword, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == healthzPath {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// requireSynced responds 503 Service Unavailable until synced reports true,
+// and serves handler afterwards.
+func requireSynced(synced func() bool, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !synced() {
+			http.Error(w, "informers not synced yet", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// normalizeRoutePrefix turns prefix into the form expected by
+// withRoutePrefix: empty, or with a leading and without a trailing slash.
+func normalizeRoutePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// withRoutePrefix serves handler below prefix, for a reverse proxy passing
+// on the paths it serves the agent at. The handler sees the paths without
+// prefix, and requests outside of it are not found.
+func withRoutePrefix(prefix string, handler http.Handler) http.Handler {
+	if prefix == "" {
+		return handler
+	}
+	stripped := http.StripPrefix(prefix, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// namespaceScoped serves the requests with a namespace query parameter from
+// the series of gatherer in that namespace, all others with handler.
+func namespaceScoped(gatherer prometheus.Gatherer, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.URL.Query().Get("namespace")
+		if ns == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		promhttp.HandlerFor(namespaceGatherer{gatherer, ns}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// instrumentMetricHandler wraps handler with the promhttp_metric_handler_*
+// metrics and registers them with reg. The vendored promhttp predates its own
+// InstrumentMetricHandler, so this mirrors its metric names and labels.
+func instrumentMetricHandler(reg prometheus.Registerer, handler http.Handler) http.Handler {
+	cnt := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "promhttp_metric_handler_requests_total",
+			Help: "Total number of scrapes by HTTP status code.",
+		},
+		[]string{"code"},
+	)
+	// Initialize the most likely HTTP status codes.
+	cnt.WithLabelValues("200")
+	cnt.WithLabelValues("500")
+	cnt.WithLabelValues("503")
+
+	gge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "promhttp_metric_handler_requests_in_flight",
+		Help: "Current number of scrapes being served.",
+	})
+
+	for _, c := range []prometheus.Collector{cnt, gge} {
+		if err := reg.Register(c); err != nil {
+			glog.Errorf("registering metric handler instrumentation failed: %s", err)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gge.Inc()
+		defer gge.Dec()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		handler.ServeHTTP(sw, r)
+		cnt.WithLabelValues(strconv.Itoa(sw.code)).Inc()
+	})
+}
+
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// jsonErrorWriter holds back the body of error responses, to be rewritten by
+// jsonErrors.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (w *jsonErrorWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	if code < http.StatusBadRequest {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *jsonErrorWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.code >= http.StatusBadRequest {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// jsonError is the body of the error responses of jsonErrors.
+type jsonError struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// jsonErrors rewrites the error responses of handler to JSON bodies of the
+// form {"error": ..., "status": ...}, for tooling rather than browsers.
+func jsonErrors(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jw := &jsonErrorWriter{ResponseWriter: w}
+		handler.ServeHTTP(jw, r)
+		if jw.code < http.StatusBadRequest {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(jw.code)
+		json.NewEncoder(w).Encode(jsonError{
+			Error:  strings.TrimSpace(jw.body.String()),
+			Status: jw.code,
+		})
+	})
+}
+
+// maxInFlight rejects requests with 503 once limit requests are being served
+// concurrently. Requests for the exempt paths are never rejected, so probes
+// keep working under scrape storms. A limit <= 0 disables the check.
+func maxInFlight(limit int, handler http.Handler, exempt ...string) http.Handler {
+	if limit <= 0 {
+		return handler
+	}
+	sem := make(chan struct{}, limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range exempt {
+			if r.URL.Path == p {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			handler.ServeHTTP(w, r)
+		default:
+			http.Error(w, "too many requests in flight", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// prefixGatherer gathers the metric families of gatherer whose names start
+// with one of prefixes.
+type prefixGatherer struct {
+	gatherer prometheus.Gatherer
+	prefixes []string
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g prefixGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	res := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		for _, p := range g.prefixes {
+			if strings.HasPrefix(mf.GetName(), p) {
+				res = append(res, mf)
+				break
+			}
+		}
+	}
+	return res, err
+}
+
+// namespaceGatherer gathers the series of gatherer whose namespace label is
+// namespace. Families left without series are dropped.
+type namespaceGatherer struct {
+	gatherer  prometheus.Gatherer
+	namespace string
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g namespaceGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	res := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		var ms []*dto.Metric
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "namespace" && l.GetValue() == g.namespace {
+					ms = append(ms, m)
+					break
+				}
+			}
+		}
+		if len(ms) > 0 {
+			res = append(res, &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Metric: ms})
+		}
+	}
+	return res, err
+}
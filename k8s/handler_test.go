@@ -0,0 +1,359 @@
+package k8s
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInstrumentedMetricsHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := metricsHandler(true, reg, reg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", metricsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", rec.Code)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %s", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "promhttp_metric_handler_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetLabel()[0].GetValue() == "200" && m.GetCounter().GetValue() != 1 {
+				t.Errorf("expected one successful scrape, got %v", m.GetCounter().GetValue())
+			}
+		}
+		return
+	}
+	t.Errorf("promhttp_metric_handler_requests_total not registered")
+}
+
+func TestMaxInFlight(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("block") != "" {
+			close(started)
+			<-block
+		}
+	})
+	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {})
+	h := maxInFlight(1, mux, healthzPath)
+
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", metricsPath+"?block=1", nil))
+	<-started
+	defer close(block)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", metricsPath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for %s, got %d", metricsPath, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", healthzPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for %s, got %d", healthzPath, rec.Code)
+	}
+}
+
+func TestMetricsServerTimeouts(t *testing.T) {
+	defer func(r, w, i time.Duration, h int) {
+		*serverReadTimeout, *serverWriteTimeout, *serverIdleTimeout, *serverMaxHeaderBytes = r, w, i, h
+	}(*serverReadTimeout, *serverWriteTimeout, *serverIdleTimeout, *serverMaxHeaderBytes)
+
+	*serverReadTimeout = 5 * time.Second
+	*serverWriteTimeout = 10 * time.Second
+	*serverIdleTimeout = 15 * time.Second
+	*serverMaxHeaderBytes = 4096
+
+	srv := newMetricsServer(":8080", http.NotFoundHandler())
+	if srv.ReadTimeout != 5*time.Second || srv.WriteTimeout != 10*time.Second ||
+		srv.IdleTimeout != 15*time.Second || srv.MaxHeaderBytes != 4096 {
+		t.Errorf("flags not applied to server: %+v", srv)
+	}
+}
+
+func TestRegisterHandler(t *testing.T) {
+	if err := RegisterHandler("/version", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v1"))
+	})); err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{metricsPath, healthzPath, "/"} {
+		if err := RegisterHandler(pattern, http.NotFoundHandler()); err == nil {
+			t.Errorf("%s: expected the pattern of a default route to be rejected", pattern)
+		}
+	}
+	mux := newMetricsMux("")
+
+	for path, body := range map[string]string{"/version": "v1", healthzPath: "ok"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != body {
+			t.Errorf("%s: expected 200 %q, got %d %q", path, body, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSubsetMetricsServers(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"app_requests", "infra_nodes"} {
+		reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: name}))
+	}
+
+	app := httptest.NewServer(newSubsetMux(reg, []string{"app_"}))
+	defer app.Close()
+	infra := httptest.NewServer(newSubsetMux(reg, []string{"infra_"}))
+	defer infra.Close()
+
+	for _, tc := range []struct {
+		url           string
+		want, notWant string
+	}{
+		{app.URL, "app_requests", "infra_nodes"},
+		{infra.URL, "infra_nodes", "app_requests"},
+	} {
+		resp, err := http.Get(tc.url + metricsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), tc.want) || strings.Contains(string(body), tc.notWant) {
+			t.Errorf("%s: expected %s but not %s, got:\n%s", tc.url, tc.want, tc.notWant, body)
+		}
+	}
+}
+
+func TestMetricsHandlerNamespaceScope(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	pods := []v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Namespace: "tenant-a", Name: "a"}},
+		{ObjectMeta: v1.ObjectMeta{Namespace: "tenant-b", Name: "b"}},
+	}
+	reg.MustRegister(&podCollector{store: PodLister(func() ([]v1.Pod, error) { return pods, nil })})
+	h := metricsHandler(true, prometheus.NewRegistry(), reg)
+
+	scrape := func(url string) string {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d", url, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := scrape(metricsPath + "?namespace=tenant-a")
+	if !strings.Contains(body, `namespace="tenant-a"`) || strings.Contains(body, `namespace="tenant-b"`) {
+		t.Errorf("expected only tenant-a series, got:\n%s", body)
+	}
+	body = scrape(metricsPath)
+	if !strings.Contains(body, `namespace="tenant-a"`) || !strings.Contains(body, `namespace="tenant-b"`) {
+		t.Errorf("expected all namespaces without the parameter, got:\n%s", body)
+	}
+}
+
+func TestMetricsHandlerProtobuf(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	pods := []v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Namespace: "tenant-a", Name: "a"}},
+		{ObjectMeta: v1.ObjectMeta{Namespace: "tenant-b", Name: "b"}},
+	}
+	reg.MustRegister(&podCollector{store: PodLister(func() ([]v1.Pod, error) { return pods, nil })})
+	h := metricsHandler(true, prometheus.NewRegistry(), reg)
+
+	scrape := func(url, accept string) (expfmt.Format, []*dto.MetricFamily) {
+		req := httptest.NewRequest("GET", url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d", url, rec.Code)
+		}
+		format := expfmt.ResponseFormat(rec.HeaderMap)
+		dec := expfmt.NewDecoder(rec.Body, format)
+		var mfs []*dto.MetricFamily
+		for {
+			mf := &dto.MetricFamily{}
+			if err := dec.Decode(mf); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: decoding %s failed: %s", url, format, err)
+			}
+			mfs = append(mfs, mf)
+		}
+		return format, mfs
+	}
+
+	accept := `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited`
+	for _, url := range []string{metricsPath, metricsPath + "?namespace=tenant-a"} {
+		format, mfs := scrape(url, accept)
+		if format != expfmt.FmtProtoDelim {
+			t.Errorf("%s: expected the delimited protobuf format, got %s", url, format)
+		}
+		if !hasMetricFamily(mfs, "kube_pod_info") {
+			t.Errorf("%s: expected kube_pod_info in the protobuf response", url)
+		}
+	}
+
+	if format, _ := scrape(metricsPath, ""); format != expfmt.FmtText {
+		t.Errorf("expected the text format by default, got %s", format)
+	}
+}
+
+func TestRoutePrefix(t *testing.T) {
+	prefix := normalizeRoutePrefix("agent/")
+	if prefix != "/agent" {
+		t.Fatalf("expected /agent, got %q", prefix)
+	}
+	h := newMetricsMux(prefix)
+
+	for _, tc := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/agent" + metricsPath, http.StatusOK, ""},
+		{"/agent" + healthzPath, http.StatusOK, "ok"},
+		{"/agent/", http.StatusOK, "href='/agent" + metricsPath + "'"},
+		{"/agent", http.StatusMovedPermanently, ""},
+		{metricsPath, http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.body) {
+			t.Errorf("%s: expected %d with %q, got %d %q", tc.path, tc.code, tc.body, rec.Code, rec.Body.String())
+		}
+	}
+	if normalizeRoutePrefix("/") != "" {
+		t.Errorf("expected an empty prefix to keep the routes at the root")
+	}
+}
+
+func TestMetricsRequireSync(t *testing.T) {
+	defer func(v bool) { *metricsRequireSync = v }(*metricsRequireSync)
+	defer setRunningInformers(nil)
+	*metricsRequireSync = true
+	pods := &fakeInformer{store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	setRunningInformers(map[string]informerState{"pods": pods})
+	mux := newMetricsMux("")
+
+	scrape := func() int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", metricsPath, nil))
+		return rec.Code
+	}
+	if code := scrape(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the informers synced, got %d", code)
+	}
+	pods.synced = true
+	if code := scrape(); code != http.StatusOK {
+		t.Errorf("expected 200 after the informers synced, got %d", code)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "informers not synced yet", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	h := jsonErrors(mux)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/fail", nil))
+	var body jsonError
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a JSON error body, got %q: %s", rec.Body, err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Content-Type") != "application/json" ||
+		body != (jsonError{Error: "informers not synced yet", Status: http.StatusServiceUnavailable}) {
+		t.Errorf("unexpected error response %d %q %+v", rec.Code, rec.Header().Get("Content-Type"), body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("expected successful responses to pass unchanged, got %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestBasicAuthCoversAllRoutes(t *testing.T) {
+	defer func(u, p string) { *metricsBasicAuthUser, *metricsBasicAuthPassword = u, p }(*metricsBasicAuthUser, *metricsBasicAuthPassword)
+	*metricsBasicAuthUser, *metricsBasicAuthPassword = "prometheus", "secret"
+	c := &MetricCollection{
+		reg:      &trackingRegisterer{Registerer: prometheus.NewRegistry()},
+		handlers: map[string]http.Handler{collectorsPath: newCollectorSwitch(prometheus.NewRegistry(), newCollectorRegistered())},
+	}
+
+	for path, want := range map[string]int{
+		metricsPath:                     http.StatusUnauthorized,
+		collectorsPath + "pods/disable": http.StatusUnauthorized,
+		"/":                             http.StatusUnauthorized,
+		healthzPath:                     http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		c.Handler(prometheus.NewRegistry()).ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d without credentials, got %d", path, want, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	requireBasicAuth(newSubsetMux(prometheus.NewRegistry(), nil)).ServeHTTP(rec, httptest.NewRequest("GET", metricsPath, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the secondary metrics to require credentials, got %d", rec.Code)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := basicAuth("prometheus", "secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+
+	for _, c := range []struct {
+		user, password string
+		set            bool
+		want           int
+	}{
+		{want: http.StatusUnauthorized},
+		{user: "prometheus", password: "wrong", set: true, want: http.StatusUnauthorized},
+		{user: "prometheus", password: "secret", set: true, want: http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", metricsPath, nil)
+		if c.set {
+			req.SetBasicAuth(c.user, c.password)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%q/%q: expected %d, got %d", c.user, c.password, c.want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	descAgentHealthy = prometheus.NewDesc(
+		"agent_healthy",
+		"Whether all health checks of the agent pass, see agent_health_check.",
+		nil, nil,
+	)
+	descAgentHealthCheck = prometheus.NewDesc(
+		"agent_health_check",
+		"Whether a health check of the agent passes.",
+		[]string{"check"}, nil,
+	)
+)
+
+// errorTime is the time of the last failed list or watch call of the
+// informers of a metric collection.
+type errorTime struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (e *errorTime) mark(t time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.t = t
+}
+
+func (e *errorTime) last() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.t
+}
+
+var healthChecks struct {
+	sync.Mutex
+	checks map[string]func() bool
+}
+
+// RegisterHealthCheck adds check as name to agent_healthy, e.g. a dependency
+// of a process embedding the metric collection. A check registered again
+// under the same name replaces the previous one.
+func RegisterHealthCheck(name string, check func() bool) {
+	healthChecks.Lock()
+	defer healthChecks.Unlock()
+	if healthChecks.checks == nil {
+		healthChecks.checks = map[string]func() bool{}
+	}
+	healthChecks.checks[name] = check
+}
+
+// healthCollector reports agent_healthy, which is 1 if all of these pass:
+//
+//	informers        all informers of the collection have synced
+//	list_watch       no list or watch call failed within window, not
+//	                 counting the NotFound errors of APIs that went away,
+//	                 which agent_collector_degraded reports
+//
+// and the checks added with RegisterHealthCheck. Each check is exported in
+// agent_health_check as well, to tell which one failed.
+type healthCollector struct {
+	// synced reports whether the informers of the collection have synced.
+	synced func() bool
+	// errors is the time of the last failed list or watch call.
+	errors *errorTime
+	window time.Duration
+	// now returns the current time; nil means time.Now.
+	now func() time.Time
+}
+
+// Describe implements the prometheus.Collector interface.
+func (hc *healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descAgentHealthy
+	ch <- descAgentHealthCheck
+}
+
+// Collect implements the prometheus.Collector interface.
+func (hc *healthCollector) Collect(ch chan<- prometheus.Metric) {
+	results := hc.check()
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	healthy := true
+	for _, name := range names {
+		healthy = healthy && results[name]
+		ch <- mustNewConstMetric(descAgentHealthCheck, prometheus.GaugeValue, boolFloat64(results[name]), name)
+	}
+	ch <- mustNewConstMetric(descAgentHealthy, prometheus.GaugeValue, boolFloat64(healthy))
+}
+
+// check runs the health checks and returns their results by name.
+func (hc *healthCollector) check() map[string]bool {
+	now := time.Now
+	if hc.now != nil {
+		now = hc.now
+	}
+	lastErr := hc.errors.last()
+
+	results := map[string]bool{
+		"informers":  hc.synced(),
+		"list_watch": lastErr.IsZero() || now().Sub(lastErr) > hc.window,
+	}
+	healthChecks.Lock()
+	defer healthChecks.Unlock()
+	for name, check := range healthChecks.checks {
+		results[name] = check()
+	}
+	return results
+}
@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAgentHealthy(t *testing.T) {
+	defer func() { healthChecks.checks = nil }()
+
+	now := time.Unix(1500000000, 0)
+	c := &MetricCollection{}
+	errors := &errorTime{}
+	hc := &healthCollector{synced: c.Synced, errors: errors, window: 5 * time.Minute, now: func() time.Time { return now }}
+	health := func() (float64, map[string]float64) {
+		var healthy float64
+		checks := map[string]float64{}
+		for _, mf := range gatherFrom(t, hc) {
+			switch mf.GetName() {
+			case "agent_healthy":
+				healthy = mf.GetMetric()[0].GetGauge().GetValue()
+			case "agent_health_check":
+				for _, m := range mf.GetMetric() {
+					checks[labelValue(m, "check")] = m.GetGauge().GetValue()
+				}
+			}
+		}
+		return healthy, checks
+	}
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	c.informers = map[string]informerState{
+		"pods":  fakeInformer{store: store, synced: true},
+		"nodes": fakeInformer{store: store, synced: false},
+	}
+	if healthy, checks := health(); healthy != 0 || checks["informers"] != 0 || checks["list_watch"] != 1 {
+		t.Errorf("expected unhealthy with an unsynced informer, got %v %v", healthy, checks)
+	}
+
+	c.informers = map[string]informerState{"pods": fakeInformer{store: store, synced: true}}
+	if healthy, _ := health(); healthy != 1 {
+		t.Errorf("expected healthy once all informers synced, got %v", healthy)
+	}
+
+	errors.mark(now.Add(-time.Minute))
+	if healthy, checks := health(); healthy != 0 || checks["list_watch"] != 0 {
+		t.Errorf("expected unhealthy after a recent watch error, got %v %v", healthy, checks)
+	}
+	errors.mark(now.Add(-10 * time.Minute))
+	if healthy, _ := health(); healthy != 1 {
+		t.Errorf("expected healthy once the watch error is older than the window, got %v", healthy)
+	}
+
+	RegisterHealthCheck("transfer", func() bool { return false })
+	if healthy, checks := health(); healthy != 0 || checks["transfer"] != 0 {
+		t.Errorf("expected unhealthy with a failing registered check, got %v %v", healthy, checks)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/meta"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newInformerLastSync() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_informer_last_sync_timestamp_seconds",
+			Help: "Unix time of the last completed sync of the informer per resource.",
+		},
+		[]string{"resource"},
+	)
+}
+
+func newInformerEvents() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_informer_events_total",
+			Help: "The number of events delivered by the informer per resource and event type.",
+		},
+		[]string{"resource", "event"},
+	)
+}
+
+func newWorkloadScaleEvents() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kube_workload_scale_events_total",
+			Help: "The number of observed changes of the desired replicas of a workload.",
+		},
+		[]string{"kind", "namespace", "name"},
+	)
+}
+
+// syncHandler returns an event handler stamping the last sync time of
+// resource in gv. Resyncs are delivered as updates whose old and new objects
+// share a resource version, which tells them apart from real changes.
+func syncHandler(resource string, gv *prometheus.GaugeVec) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, err := meta.Accessor(oldObj)
+			if err != nil {
+				return
+			}
+			n, err := meta.Accessor(newObj)
+			if err != nil {
+				return
+			}
+			if o.GetResourceVersion() == n.GetResourceVersion() {
+				markSynced(resource, gv)
+			}
+		},
+	}
+}
+
+// eventCounter returns an event handler counting the add, update and delete
+// events of the informer of resource in cv. Resyncs count as updates.
+func eventCounter(resource string, cv *prometheus.CounterVec) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) {
+			cv.WithLabelValues(resource, "add").Inc()
+		},
+		UpdateFunc: func(interface{}, interface{}) {
+			cv.WithLabelValues(resource, "update").Inc()
+		},
+		DeleteFunc: func(interface{}) {
+			cv.WithLabelValues(resource, "delete").Inc()
+		},
+	}
+}
+
+func markSynced(resource string, gv *prometheus.GaugeVec) {
+	gv.WithLabelValues(resource).Set(float64(time.Now().Unix()))
+}
+
+// scaleHandler returns an event handler counting changes of the desired
+// replicas of deployments and replication controllers in cv. The counters of
+// deleted objects are removed, so that their series do not outlive them.
+func scaleHandler(cv *prometheus.CounterVec) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			// Deletions missed while the watch was down are delivered on
+			// relist as tombstones.
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			switch o := obj.(type) {
+			case *v1beta1.Deployment:
+				cv.DeleteLabelValues("Deployment", o.Namespace, o.Name)
+			case *v1.ReplicationController:
+				cv.DeleteLabelValues("ReplicationController", o.Namespace, o.Name)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			switch n := newObj.(type) {
+			case *v1beta1.Deployment:
+				if o, ok := oldObj.(*v1beta1.Deployment); ok && replicas(o.Spec.Replicas) != replicas(n.Spec.Replicas) {
+					cv.WithLabelValues("Deployment", n.Namespace, n.Name).Inc()
+				}
+			case *v1.ReplicationController:
+				if o, ok := oldObj.(*v1.ReplicationController); ok && replicas(o.Spec.Replicas) != replicas(n.Spec.Replicas) {
+					cv.WithLabelValues("ReplicationController", n.Namespace, n.Name).Inc()
+				}
+			}
+		},
+	}
+}
+
+// replicas dereferences a desired replica count; unset means the default of 1.
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+// informerState is the part of a cache.SharedInformer reported by
+// informersHandler.
+type informerState interface {
+	GetStore() cache.Store
+	HasSynced() bool
+}
+
+// runningInformers are the informers of the metric collection served by the
+// metrics server of the agent.
+var runningInformers struct {
+	sync.RWMutex
+	states map[string]informerState
+}
+
+// setRunningInformers replaces the informers checked by informersSynced.
+func setRunningInformers(states map[string]informerState) {
+	runningInformers.Lock()
+	defer runningInformers.Unlock()
+	runningInformers.states = states
+}
+
+// informersSynced reports whether the metric collection runs and all of its
+// informers have synced.
+func informersSynced() bool {
+	runningInformers.RLock()
+	defer runningInformers.RUnlock()
+	if runningInformers.states == nil {
+		return false
+	}
+	for _, inf := range runningInformers.states {
+		if !inf.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// informerStatus is the JSON reported per resource by informersHandler.
+type informerStatus struct {
+	Count  int  `json:"count"`
+	Synced bool `json:"synced"`
+}
+
+// informersHandler serves the number of cached objects and the sync state of
+// each informer as JSON, to tell whether the caches are warm without
+// Prometheus.
+func informersHandler(informers map[string]informerState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		res := make(map[string]informerStatus, len(informers))
+		for resource, inf := range informers {
+			res[resource] = informerStatus{
+				Count:  len(inf.GetStore().ListKeys()),
+				Synced: inf.HasSynced(),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	})
+}
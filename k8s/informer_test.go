@@ -0,0 +1,159 @@
+package k8s
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func gaugeValue(t *testing.T, gv *prometheus.GaugeVec, lv ...string) float64 {
+	var m dto.Metric
+	if err := gv.WithLabelValues(lv...).Write(&m); err != nil {
+		t.Fatalf("writing metric failed: %s", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func counterValue(t *testing.T, cv *prometheus.CounterVec, lv ...string) float64 {
+	var m dto.Metric
+	if err := cv.WithLabelValues(lv...).Write(&m); err != nil {
+		t.Fatalf("writing metric failed: %s", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestSyncHandlerStampsResync(t *testing.T) {
+	gv := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_last_sync"}, []string{"resource"})
+	h := syncHandler("pods", gv)
+
+	old := &v1.Pod{ObjectMeta: v1.ObjectMeta{Name: "p", ResourceVersion: "1"}}
+	changed := &v1.Pod{ObjectMeta: v1.ObjectMeta{Name: "p", ResourceVersion: "2"}}
+
+	h.OnUpdate(old, changed)
+	if v := gaugeValue(t, gv, "pods"); v != 0 {
+		t.Errorf("real update must not count as sync, got %v", v)
+	}
+
+	h.OnUpdate(changed, changed)
+	if v := gaugeValue(t, gv, "pods"); v <= 0 {
+		t.Errorf("expected sync timestamp to be set, got %v", v)
+	}
+}
+
+func TestEventCounterCountsEvents(t *testing.T) {
+	cv := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_informer_events", Help: "help"}, []string{"resource", "event"})
+	h := eventCounter("pods", cv)
+
+	pod := &v1.Pod{ObjectMeta: v1.ObjectMeta{Name: "p", ResourceVersion: "1"}}
+	h.OnAdd(pod)
+	h.OnAdd(pod)
+	h.OnUpdate(pod, pod)
+	h.OnDelete(pod)
+
+	for event, want := range map[string]float64{"add": 2, "update": 1, "delete": 1} {
+		if v := counterValue(t, cv, "pods", event); v != want {
+			t.Errorf("expected %v %s events, got %v", want, event, v)
+		}
+	}
+}
+
+func TestScaleHandlerCountsReplicaChanges(t *testing.T) {
+	cv := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_scale_events"}, []string{"kind", "namespace", "name"})
+	h := scaleHandler(cv)
+
+	deployment := func(r int32) *v1beta1.Deployment {
+		return &v1beta1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "web"},
+			Spec:       v1beta1.DeploymentSpec{Replicas: &r},
+		}
+	}
+	h.OnUpdate(deployment(3), deployment(5))
+	h.OnUpdate(deployment(5), deployment(5))
+
+	var m dto.Metric
+	if err := cv.WithLabelValues("Deployment", "ns", "web").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetCounter().GetValue(); v != 1 {
+		t.Errorf("expected one scale event, got %v", v)
+	}
+}
+
+func TestScaleHandlerPurgesDeletedObjects(t *testing.T) {
+	cv := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_scale_events", Help: "help"}, []string{"kind", "namespace", "name"})
+	h := scaleHandler(cv)
+
+	rc := func(name string, r int32) *v1.ReplicationController {
+		return &v1.ReplicationController{
+			ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       v1.ReplicationControllerSpec{Replicas: &r},
+		}
+	}
+	h.OnUpdate(rc("a", 1), rc("a", 2))
+	h.OnUpdate(rc("b", 1), rc("b", 2))
+	h.OnDelete(rc("a", 2))
+	h.OnDelete(cache.DeletedFinalStateUnknown{Key: "ns/b", Obj: rc("b", 2)})
+
+	if mfs := gatherFrom(t, cv); len(mfs) != 0 {
+		t.Errorf("expected the series of deleted objects to be gone, got %v", mfs)
+	}
+}
+
+func TestDeletedObjectSeriesGone(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	pod := &v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"}}
+	store.Add(pod)
+	pc := &podCollector{store: PodLister(func() (pods []v1.Pod, err error) {
+		for _, m := range store.List() {
+			pods = append(pods, *m.(*v1.Pod))
+		}
+		return pods, nil
+	})}
+
+	if !hasMetricFamily(gatherFrom(t, pc), "kube_pod_info") {
+		t.Fatal("expected kube_pod_info before the deletion")
+	}
+	store.Delete(pod)
+	if mfs := gatherFrom(t, pc); len(mfs) != 0 {
+		t.Errorf("expected no series after the deletion, got %v", mfs)
+	}
+}
+
+type fakeInformer struct {
+	store  cache.Store
+	synced bool
+}
+
+func (f fakeInformer) GetStore() cache.Store { return f.store }
+func (f fakeInformer) HasSynced() bool       { return f.synced }
+
+func TestInformersHandler(t *testing.T) {
+	pods := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	pods.Add(&v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "a"}})
+	pods.Add(&v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "b"}})
+	h := informersHandler(map[string]informerState{
+		"pods":  fakeInformer{store: pods, synced: true},
+		"nodes": fakeInformer{store: cache.NewStore(cache.MetaNamespaceKeyFunc)},
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/informers", nil))
+	var res map[string]informerStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %q failed: %s", rec.Body, err)
+	}
+	want := map[string]informerStatus{
+		"pods":  {Count: 2, Synced: true},
+		"nodes": {Count: 0, Synced: false},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("expected %v, got %v", want, res)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/util/intstr"
+)
+
+// ingressGroupVersions lists the group versions serving ingresses, most
+// preferred first. Clusters that dropped extensions/v1beta1 only serve
+// networking.k8s.io/v1.
+var ingressGroupVersions = []string{
+	"networking.k8s.io/v1",
+	"extensions/v1beta1",
+}
+
+var (
+	descIngressInfo = newObjectDesc(
+		"kube_ingress_info",
+		"Information about an ingress.",
+		[]string{"namespace", "ingress"},
+	)
+	descIngressPath = newObjectDesc(
+		"kube_ingress_path",
+		"A path of an ingress rule and the service it routes to.",
+		[]string{"namespace", "ingress", "host", "path", "service_name", "service_port"},
+	)
+)
+
+// ingress holds the fields of an Ingress the collector needs, decoded from
+// the raw list response of whichever group version the server serves, so
+// that the metrics are the same for all of them.
+type ingress struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec          struct {
+		Rules []ingressRule `json:"rules"`
+	} `json:"spec"`
+}
+
+type ingressRule struct {
+	Host string `json:"host"`
+	HTTP *struct {
+		Paths []ingressPath `json:"paths"`
+	} `json:"http"`
+}
+
+type ingressPath struct {
+	Path    string         `json:"path"`
+	Backend ingressBackend `json:"backend"`
+}
+
+// ingressBackend is the backend of networking.k8s.io/v1, service, or of
+// extensions/v1beta1, serviceName and servicePort.
+type ingressBackend struct {
+	Service *struct {
+		Name string `json:"name"`
+		Port struct {
+			Name   string `json:"name"`
+			Number int32  `json:"number"`
+		} `json:"port"`
+	} `json:"service"`
+	ServiceName string             `json:"serviceName"`
+	ServicePort intstr.IntOrString `json:"servicePort"`
+}
+
+// service returns the name and port of the backend service, empty for
+// resource backends.
+func (b ingressBackend) service() (name, port string) {
+	if b.Service != nil {
+		if b.Service.Port.Name != "" {
+			return b.Service.Name, b.Service.Port.Name
+		}
+		return b.Service.Name, strconv.Itoa(int(b.Service.Port.Number))
+	}
+	if b.ServiceName == "" {
+		return "", ""
+	}
+	return b.ServiceName, b.ServicePort.String()
+}
+
+type ingressList struct {
+	Items []ingress `json:"items"`
+}
+
+type ingressStore interface {
+	List() ([]ingress, error)
+}
+
+// ingressCollector collects metrics about all ingresses in the cluster.
+type ingressCollector struct {
+	store      ingressStore
+	namespaces namespaceFilter
+}
+
+// Describe implements the prometheus.Collector interface.
+func (ic *ingressCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descIngressInfo)
+	ch <- objectDesc(descIngressPath)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (ic *ingressCollector) Collect(ch chan<- prometheus.Metric) {
+	ingresses, err := ic.store.List()
+	if err != nil {
+		glog.Errorf("listing ingresses failed: %s", err)
+		return
+	}
+	for _, i := range ingresses {
+		if !ic.namespaces.allowed(i.Namespace) {
+			continue
+		}
+		ic.collectIngress(ch, i)
+	}
+}
+
+func (ic *ingressCollector) collectIngress(ch chan<- prometheus.Metric, i ingress) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		lv = append([]string{i.Namespace, i.Name}, lv...)
+		ch <- mustNewObjectMetric(desc, i.UID, prometheus.GaugeValue, v, lv...)
+	}
+	addGauge(descIngressInfo, 1)
+	for _, r := range i.Spec.Rules {
+		if r.HTTP == nil {
+			continue
+		}
+		for _, p := range r.HTTP.Paths {
+			name, port := p.Backend.service()
+			addGauge(descIngressPath, 1, r.Host, p.Path, name, port)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package k8s
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIngressCollectorVersions(t *testing.T) {
+	for gv, item := range map[string]string{
+		"networking.k8s.io/v1": `{"metadata": {"namespace": "ns", "name": "web"}, "spec": {"rules": [
+			{"host": "example.com", "http": {"paths": [{"path": "/", "backend": {"service": {"name": "web", "port": {"number": 80}}}}]}}]}}`,
+		"extensions/v1beta1": `{"metadata": {"namespace": "ns", "name": "web"}, "spec": {"rules": [
+			{"host": "example.com", "http": {"paths": [{"path": "/", "backend": {"serviceName": "web", "servicePort": 80}}]}}]}}`,
+	} {
+		var l ingressList
+		if err := json.Unmarshal([]byte(`{"items": [`+item+`]}`), &l); err != nil {
+			t.Fatalf("%s: decoding failed: %s", gv, err)
+		}
+		ic := &ingressCollector{store: IngressLister(func() ([]ingress, error) { return l.Items, nil })}
+
+		found := map[string]bool{}
+		for _, mf := range gatherFrom(t, ic) {
+			m := mf.GetMetric()[0]
+			if labelValue(m, "ingress") != "web" {
+				t.Errorf("%s: %s: unexpected series %v", gv, mf.GetName(), m)
+			}
+			if mf.GetName() == "kube_ingress_path" {
+				if labelValue(m, "host") != "example.com" || labelValue(m, "path") != "/" ||
+					labelValue(m, "service_name") != "web" || labelValue(m, "service_port") != "80" {
+					t.Errorf("%s: unexpected path series %v", gv, m)
+				}
+			}
+			found[mf.GetName()] = true
+		}
+		if !found["kube_ingress_info"] || !found["kube_ingress_path"] {
+			t.Errorf("%s: expected info and path series, got %v", gv, found)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sync"
+
+	"k8s.io/client-go/pkg/api/meta"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// maxInterned bounds the strings kept by an internPool, so churning values
+// cannot grow it without limit.
+const maxInterned = 1 << 16
+
+// internPool hands out one shared copy of equal strings. Every decoded object
+// carries its own copy of e.g. its namespace, which dominates the memory of
+// the informer caches on huge clusters; the metric labels built from cached
+// objects then share the same copies.
+type internPool struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+var interned = &internPool{strings: map[string]string{}}
+
+// intern returns the shared copy of s.
+func (p *internPool) intern(s string) string {
+	if s == "" {
+		return s
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if is, ok := p.strings[s]; ok {
+		return is
+	}
+	if len(p.strings) >= maxInterned {
+		p.strings = map[string]string{}
+	}
+	p.strings[s] = s
+	return s
+}
+
+// internObject replaces the strings obj shares with many other objects by
+// their interned copies: the namespace and, for pods, the node and host IP.
+func internObject(obj runtime.Object) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	m.SetNamespace(interned.intern(m.GetNamespace()))
+	if p, ok := obj.(*v1.Pod); ok {
+		p.Spec.NodeName = interned.intern(p.Spec.NodeName)
+		p.Status.HostIP = interned.intern(p.Status.HostIP)
+	}
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// decodedPod returns a pod with its own copies of its strings, as decoded
+// from the apiserver.
+func decodedPod(namespace, node string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: string([]byte(namespace)), Name: "p"},
+		Spec:       v1.PodSpec{NodeName: string([]byte(node))},
+	}
+}
+
+func TestInternObject(t *testing.T) {
+	a, b := decodedPod("shared", "node-1"), decodedPod("shared", "node-1")
+	internObject(a)
+	internObject(b)
+	if a.Namespace != "shared" || a.Spec.NodeName != "node-1" {
+		t.Fatalf("interning changed the values: %q %q", a.Namespace, a.Spec.NodeName)
+	}
+	if unsafe.StringData(a.Namespace) != unsafe.StringData(b.Namespace) || unsafe.StringData(a.Spec.NodeName) != unsafe.StringData(b.Spec.NodeName) {
+		t.Errorf("expected equal strings to share storage")
+	}
+}
+
+// BenchmarkCachePods reports the heap retained by caching pods that all live
+// in the same namespace and on the same node, with and without interning.
+func BenchmarkCachePods(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		transform func(*v1.Pod)
+	}{
+		{"plain", func(*v1.Pod) {}},
+		{"interned", func(p *v1.Pod) { internObject(p) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			cache := make([]*v1.Pod, 0, b.N)
+			for i := 0; i < b.N; i++ {
+				p := decodedPod("a-rather-long-shared-namespace-name", "ip-10-0-0-1.eu-west-1.compute.internal")
+				bc.transform(p)
+				cache = append(cache, p)
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N), "retained-B/op")
+			runtime.KeepAlive(cache)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+)
+
+var (
+	descJobDurationSeconds = newObjectDesc(
+		"kube_job_duration_seconds",
+		"Time a completed job took from start to completion.",
+		[]string{"namespace", "job"},
+	)
+	descJobActiveSeconds = newObjectDesc(
+		"kube_job_active_seconds",
+		"Time a job that has not completed yet has been running since its start.",
+		[]string{"namespace", "job"},
+	)
+)
+
+type jobStore interface {
+	List() ([]batchv1.Job, error)
+}
+
+// jobCollector collects the run times of all jobs in the cluster, e.g. to
+// alert on batch SLA breaches.
+type jobCollector struct {
+	store      jobStore
+	namespaces namespaceFilter
+	// now returns the current time; nil means time.Now.
+	now func() time.Time
+}
+
+// Describe implements the prometheus.Collector interface.
+func (jc *jobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descJobDurationSeconds)
+	ch <- objectDesc(descJobActiveSeconds)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (jc *jobCollector) Collect(ch chan<- prometheus.Metric) {
+	jobs, err := jc.store.List()
+	if err != nil {
+		glog.Errorf("listing jobs failed: %s", err)
+		return
+	}
+	now := time.Now()
+	if jc.now != nil {
+		now = jc.now()
+	}
+	for _, j := range jobs {
+		if !jc.namespaces.allowed(j.Namespace) {
+			continue
+		}
+		jc.collectJob(ch, j, now)
+	}
+}
+
+func (jc *jobCollector) collectJob(ch chan<- prometheus.Metric, j batchv1.Job, now time.Time) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		lv = append([]string{j.Namespace, j.Name}, lv...)
+		ch <- mustNewObjectMetric(desc, j.UID, prometheus.GaugeValue, v, lv...)
+	}
+	// Jobs not picked up by the job controller yet have no start time.
+	if j.Status.StartTime == nil {
+		return
+	}
+	if j.Status.CompletionTime != nil {
+		addGauge(descJobDurationSeconds, j.Status.CompletionTime.Sub(j.Status.StartTime.Time).Seconds())
+	} else {
+		addGauge(descJobActiveSeconds, now.Sub(j.Status.StartTime.Time).Seconds())
+	}
+}
@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+)
+
+func TestJobCollector(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	startTime := unversioned.NewTime(start)
+	completionTime := unversioned.NewTime(start.Add(120 * time.Second))
+	jobs := []batchv1.Job{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "completed"},
+		Status:     batchv1.JobStatus{StartTime: &startTime, CompletionTime: &completionTime},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "running"},
+		Status:     batchv1.JobStatus{StartTime: &startTime, Active: 1},
+	}}
+	jc := &jobCollector{
+		store: JobLister(func() ([]batchv1.Job, error) { return jobs, nil }),
+		now:   func() time.Time { return start.Add(time.Hour) },
+	}
+
+	mfs := gatherFrom(t, jc)
+	if len(mfs) != 2 {
+		t.Fatalf("expected duration and active seconds, got %d metric families", len(mfs))
+	}
+	for _, mf := range mfs {
+		if len(mf.GetMetric()) != 1 {
+			t.Fatalf("%s: expected one series, got %d", mf.GetName(), len(mf.GetMetric()))
+		}
+		m := mf.GetMetric()[0]
+		switch mf.GetName() {
+		case "kube_job_duration_seconds":
+			if job, v := labelValue(m, "job"), m.GetGauge().GetValue(); job != "completed" || v != 120 {
+				t.Errorf("expected completed job to have run 120s, got %s %vs", job, v)
+			}
+		case "kube_job_active_seconds":
+			if job, v := labelValue(m, "job"), m.GetGauge().GetValue(); job != "running" || v != 3600 {
+				t.Errorf("expected running job to be active for 3600s, got %s %vs", job, v)
+			}
+		}
+	}
+}
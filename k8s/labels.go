@@ -0,0 +1,166 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/types"
+)
+
+// objectDescVariant holds what a desc was created from and the descs emitted
+// in its place, with the help overridden by --help-overrides and, for
+// metrics about a single object, with the uid label added by --uid-labels.
+type objectDescVariant struct {
+	fqName         string
+	help           string
+	variableLabels []string
+	// uid is set for metrics about a single object, which get the uid
+	// label.
+	uid bool
+
+	plain, withUID *prometheus.Desc
+}
+
+// objectDescs maps the descs created by newObjectDesc and newDesc to their
+// variants.
+var objectDescs = map[*prometheus.Desc]*objectDescVariant{}
+
+// newObjectDesc is prometheus.NewDesc for metrics about a single object.
+// It also creates the variant of the desc with a trailing uid label.
+func newObjectDesc(fqName, help string, variableLabels []string) *prometheus.Desc {
+	desc := newDesc(fqName, help, variableLabels)
+	objectDescs[desc].uid = true
+	return desc
+}
+
+// newDesc is prometheus.NewDesc for metrics not about a single object, e.g.
+// aggregates or series that must outlive the objects they describe. Their
+// help can be overridden, but they never get the uid label.
+func newDesc(fqName, help string, variableLabels []string) *prometheus.Desc {
+	desc := prometheus.NewDesc(fqName, help, variableLabels, nil)
+	v := &objectDescVariant{fqName: fqName, help: help, variableLabels: variableLabels}
+	v.setHelp(help)
+	objectDescs[desc] = v
+	return desc
+}
+
+func (v *objectDescVariant) setHelp(help string) {
+	withUID := append(v.variableLabels[:len(v.variableLabels):len(v.variableLabels)], "uid")
+	v.plain = prometheus.NewDesc(v.fqName, help, v.variableLabels, nil)
+	v.withUID = prometheus.NewDesc(v.fqName, help, withUID, nil)
+}
+
+// overrideHelp replaces the help of the metrics named in help and returns
+// the names that match no metric, sorted. The others keep their default
+// help. It must be called before the collectors are registered.
+func overrideHelp(help map[string]string) []string {
+	known := map[string]bool{}
+	for _, v := range objectDescs {
+		known[v.fqName] = true
+		if h, ok := help[v.fqName]; ok {
+			v.setHelp(h)
+		} else {
+			v.setHelp(v.help)
+		}
+	}
+	var unknown []string
+	for name := range help {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// loadHelpOverrides reads a JSON object of metric names to help texts from
+// path and applies it with overrideHelp.
+func loadHelpOverrides(path string) error {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var help map[string]string
+	if err := json.Unmarshal(bs, &help); err != nil {
+		return fmt.Errorf("parsing %s failed: %s", path, err)
+	}
+	for _, name := range overrideHelp(help) {
+		glog.Warningf("--help-overrides: no metric %s to override the help of", name)
+	}
+	return nil
+}
+
+// objectDesc returns the desc collectors describe and emit for desc, which
+// has the overridden help and, for metrics about a single object, the uid
+// label if --uid-labels is set.
+func objectDesc(desc *prometheus.Desc) *prometheus.Desc {
+	v, ok := objectDescs[desc]
+	if !ok {
+		return desc
+	}
+	if *uidLabels && v.uid {
+		return v.withUID
+	}
+	return v.plain
+}
+
+// mustNewObjectMetric is mustNewConstMetric for metrics about the object with
+// the given uid. Names are reused once an object is deleted, so with
+// --uid-labels the uid is added to tell the series apart.
+func mustNewObjectMetric(desc *prometheus.Desc, uid types.UID, t prometheus.ValueType, v float64, lv ...string) prometheus.Metric {
+	if v, ok := objectDescs[desc]; ok && v.uid && *uidLabels {
+		lv = append(lv[:len(lv):len(lv)], string(uid))
+	}
+	return mustNewConstMetric(objectDesc(desc), t, v, lv...)
+}
+
+// mustNewConstMetric is prometheus.MustNewConstMetric with the label values
+// sanitized. Names and annotation values copied into labels are user
+// controlled, so every collector emits through it.
+func mustNewConstMetric(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) prometheus.Metric {
+	sanitized := make([]string, len(lv))
+	for i, l := range lv {
+		sanitized[i] = sanitizeLabelValue(l)
+	}
+	return prometheus.MustNewConstMetric(desc, t, v, sanitized...)
+}
+
+// sanitizeLabelValue replaces invalid UTF-8 sequences with U+FFFD and, if
+// --strip-label-control-chars is set, drops control characters.
+func sanitizeLabelValue(s string) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	if !*stripLabelControlChars {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
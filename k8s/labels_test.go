@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestSanitizeLabelValue(t *testing.T) {
+	defer func(v bool) { *stripLabelControlChars = v }(*stripLabelControlChars)
+
+	*stripLabelControlChars = false
+	if v := sanitizeLabelValue("bad\xffname"); v != "bad�name" {
+		t.Errorf("expected invalid UTF-8 to be replaced, got %q", v)
+	}
+	if v := sanitizeLabelValue("web\x07-1"); v != "web\x07-1" {
+		t.Errorf("expected control characters to be kept by default, got %q", v)
+	}
+
+	*stripLabelControlChars = true
+	if v := sanitizeLabelValue("web\x07-1\n"); v != "web-1" {
+		t.Errorf("expected control characters to be stripped, got %q", v)
+	}
+}
+
+func TestUIDLabels(t *testing.T) {
+	defer func(v bool) { *uidLabels = v }(*uidLabels)
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p", UID: "1234"},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+
+	*uidLabels = false
+	for _, m := range podMetrics(t, &podCollector{}, pod)["kube_pod_status_phase"] {
+		if uid := labelValue(m, "uid"); uid != "" {
+			t.Errorf("expected no uid label by default, got %q", uid)
+		}
+	}
+
+	*uidLabels = true
+	ms := podMetrics(t, &podCollector{}, pod)
+	for _, name := range []string{"kube_pod_status_phase", "kube_pod_status_ready"} {
+		if len(ms[name]) == 0 {
+			t.Fatalf("%s missing", name)
+		}
+		for _, m := range ms[name] {
+			if uid := labelValue(m, "uid"); uid != "1234" {
+				t.Errorf("%s: expected uid label 1234, got %q", name, uid)
+			}
+		}
+	}
+}
+
+func TestLoadHelpOverrides(t *testing.T) {
+	f, err := ioutil.TempFile("", "help-overrides")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"kube_pod_info": "Pod metadata, see the runbook."}`)
+	f.Close()
+
+	if err := loadHelpOverrides(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	defer overrideHelp(nil)
+
+	pod := v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"}}
+	help := map[string]string{}
+	for _, mf := range gatherFrom(t, &podCollector{store: PodLister(func() ([]v1.Pod, error) { return []v1.Pod{pod}, nil })}) {
+		help[mf.GetName()] = mf.GetHelp()
+	}
+	if h := help["kube_pod_info"]; h != "Pod metadata, see the runbook." {
+		t.Errorf("expected the overridden help, got %q", h)
+	}
+	if h := help["kube_pod_status_phase"]; h != "The pods current phase." {
+		t.Errorf("expected the default help of metrics not overridden, got %q", h)
+	}
+}
+
+func TestOverrideHelpOfPlainDescs(t *testing.T) {
+	unknown := overrideHelp(map[string]string{
+		"kube_node_lease_renew_age_seconds": "Lease age, see the runbook.",
+		"kube_node_lease_age":               "A typo.",
+	})
+	defer overrideHelp(nil)
+	if len(unknown) != 1 || unknown[0] != "kube_node_lease_age" {
+		t.Errorf("expected kube_node_lease_age reported as unknown, got %v", unknown)
+	}
+
+	var l lease
+	l.Name = "node-1"
+	l.Spec.RenewTime = "2017-01-01T11:59:30.000000Z"
+	lc := &nodeLeaseCollector{
+		store: LeaseLister(func() ([]lease, error) { return []lease{l}, nil }),
+		now:   func() time.Time { return time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	mfs := gatherFrom(t, lc)
+	if len(mfs) != 1 || mfs[0].GetHelp() != "Lease age, see the runbook." {
+		t.Errorf("expected the overridden help, got %v", mfs)
+	}
+}
@@ -17,21 +17,29 @@ limitations under the License.
 package k8s
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	dto "github.com/prometheus/client_model/go"
 	flag "github.com/spf13/pflag"
-	"golang.org/x/net/context"
+	"k8s.io/client-go/discovery"
 	clientset "k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/pkg/api"
+	apierrors "k8s.io/client-go/pkg/api/errors"
 	"k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
 	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/util/wait"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
@@ -39,8 +47,14 @@ import (
 
 const (
 	resyncPeriod = 5 * time.Minute
-	metricsPath  = "/metrics"
-	healthzPath  = "/healthz"
+	// componentStatusPollPeriod is how often component statuses are listed;
+	// they cannot be watched.
+	componentStatusPollPeriod = time.Minute
+	// resourceVersionSavePeriod is how often the synced resource versions
+	// are written to --resource-version-file.
+	resourceVersionSavePeriod = time.Minute
+	metricsPath               = "/metrics"
+	healthzPath               = "/healthz"
 )
 
 var (
@@ -52,12 +66,105 @@ var (
 
 	kubeconfig = flags.String("kubeconfig", "./config", "absolute path to the kubeconfig file")
 
+	discoveryKubeconfig = flags.String("discovery-kubeconfig", "", `Path of a kubeconfig used only for discovery and the version check, e.g. with credentials allowed no more than that; defaults to the client of the watches`)
+
+	kubeconfigReloadInterval = flags.Duration("kubeconfig-reload-interval", 0, `How often to check --kubeconfig for changes, rebuilding the client and restarting the informers on rotated credentials; 0 disables it`)
+
+	resourceVersionFile = flags.String("resource-version-file", "", `If set, persist the resource version each informer last synced to this file and, after a restart, list from it so the watches resume there; versions too old for the apiserver are listed afresh`)
+
+	listPageSize = flags.Int64("list-page-size", 0, `Number of objects per page when the informers list a resource, to bound the size of the lists on large clusters. Paged lists bypass the watch cache of the apiserver and read from etcd; 0 lists everything at once from the watch cache`)
+
+	initialListRetries = flags.Int("initial-list-retries", 5, `How often to retry the first list of a resource on errors before leaving it to the informer's own retries`)
+
+	initialListBackoff = flags.Duration("initial-list-backoff", time.Second, `Wait before the first retry of a failed initial list, doubled for each further retry`)
+
+	healthWatchErrorWindow = flags.Duration("health-watch-error-window", 5*time.Minute, `How long a failed list or watch call marks the agent unhealthy in agent_healthy`)
+
+	informerSyncDeadline = flags.Duration("informer-sync-deadline", 10*time.Minute, `How long an informer may take to sync before it is rebuilt, e.g. after a silently failed watch; 0 disables rebuilding`)
+
 	help = flags.BoolP("help", "h", false, "Print help text")
 
 	port = flags.Int("port", 80, `Port to expose metrics on.`)
+
+	routePrefix = flags.String("route-prefix", "", `Path prefix of all routes of the metrics server, e.g. /agent when a reverse proxy serves the metrics at /agent/metrics`)
+
+	secondaryPort = flags.Int("secondary-port", 0, `Port of a second metrics server exposing only the metrics selected by --secondary-metrics; 0 disables it`)
+
+	secondaryMetrics = flags.StringSlice("secondary-metrics", nil, `Comma separated metric name prefixes served on --secondary-port, e.g. kube_pod_`)
+
+	apiserverTimeout = flags.Duration("apiserver-timeout", 10*time.Second, `Timeout for the initial apiserver connectivity check`)
+
+	serverReadTimeout = flags.Duration("server-read-timeout", 30*time.Second, `Maximum duration for reading an entire request to the metrics server`)
+
+	serverWriteTimeout = flags.Duration("server-write-timeout", time.Minute, `Maximum duration before timing out writes of a metrics server response`)
+
+	serverIdleTimeout = flags.Duration("server-idle-timeout", 2*time.Minute, `Maximum time to keep idle metrics server connections alive`)
+
+	serverMaxHeaderBytes = flags.Int("server-max-header-bytes", 1<<20, `Maximum size of request headers accepted by the metrics server`)
+
+	maxRequestsInFlight = flags.Int("max-requests-in-flight", 0, `Maximum number of concurrent requests served before replying 503; 0 means no limit. `+healthzPath+` is exempt`)
+
+	uidLabels = flags.Bool("uid-labels", false, `If true, add the object's uid as a uid label to all object metrics, telling apart objects that reuse a name`)
+
+	relabelConfigFile = flags.String("relabel-config", "", `Path of a JSON file with a list of relabeling rules (source_labels, separator, regex, target_label, replacement and action replace, keep or drop) applied to the series before they are exposed`)
+	helpOverrides     = flags.String("help-overrides", "", `Path of a JSON file mapping metric names to the help text to expose them with instead of the default`)
+
+	stripLabelControlChars = flags.Bool("strip-label-control-chars", false, `If true, drop control characters from label values`)
+
+	stripAnnotations = flags.Bool("strip-annotations", true, `If true, drop annotations the collectors do not read from cached objects to save memory`)
+
+	metricNamespaces = flags.StringSlice("metric-namespaces", nil, `Comma separated namespaces to emit metrics for while still watching all of them; empty means all`)
+
+	nodeSelectorKeys = flags.StringSlice("node-selector-keys", nil, `Comma separated pod node selector keys to export in kube_pod_spec_node_selector`)
+
+	podRestartThreshold = flags.Int32("pod-restart-threshold", 0, `If positive, export kube_pod_restart_threshold_exceeded, which is 1 for pods with a container restarted at least this many times`)
+
+	labelHashKeys = flags.StringSlice("label-hash-keys", nil, `Comma separated pod label keys hashed into the labels_hash label of kube_pod_labels_hash`)
+
+	criticalDeployments = flags.StringSlice("critical-deployments", nil, `Comma separated namespace/name of deployments to report in kube_critical_deployment_available, e.g. kube-system/kube-dns`)
+
+	owner = flags.String("owner", "", `Only emit pod metrics for pods owned, directly or through ReplicaSets, by this kind/namespace/name, e.g. Deployment/default/web`)
+
+	pushgatewayURL = flags.String("pushgateway-url", "", `If set, additionally push metrics to the Prometheus Pushgateway at this URL`)
+
+	pushgatewayJob = flags.String("pushgateway-job", "kube-metrics", `Job name used when pushing to the Pushgateway`)
+
+	pushgatewayInterval = flags.Duration("pushgateway-interval", time.Minute, `Interval between pushes to the Pushgateway`)
+
+	metricsFile = flags.String("metrics-file", "", `If set, additionally write metrics in the Prometheus text format to this file, replaced atomically, for air-gapped environments without a scraper`)
+
+	metricsFileInterval = flags.Duration("metrics-file-interval", time.Minute, `Interval between writes of --metrics-file`)
+
+	instrumentMetricsHandler = flags.Bool("instrument-metrics-handler", false, `If true, serve metrics through promhttp and count the scrapes of the metrics endpoint itself`)
+
+	metricsBasicAuthUser = flags.String("metrics-basic-auth-user", "", `If set, every route of the metrics servers but `+healthzPath+` requires HTTP basic auth with this user and --metrics-basic-auth-password`)
+
+	metricsBasicAuthPassword = flags.String("metrics-basic-auth-password", "", `Password required by --metrics-basic-auth-user`)
+
+	metricsRequireSync = flags.Bool("metrics-require-sync", false, `If true, `+metricsPath+` returns 503 until all informers have synced, instead of the incomplete metrics of a warming up agent`)
+
+	jsonErrorResponses = flags.Bool("json-errors", false, `If true, the metrics server responds to errors with JSON bodies of the form {"error": ..., "status": ...} instead of plain text`)
+
+	serviceaccountMetrics = flags.Bool("serviceaccount-metrics", false, `If true, watch the service accounts of all namespaces and export kube_serviceaccount_*; needs RBAC access to list and watch serviceaccounts`)
+
+	rawListPeriod = flags.Duration("raw-list-period", 30*time.Second, `How often endpoint slices, node leases and ingresses, which cannot be watched, are listed into the cache the metrics are read from`)
+
+	snapshotDebounce = flags.Duration("snapshot-debounce", 0, `If positive, serve the deployment, pod, node and replication controller metrics from snapshots rebuilt at most once per this window after their informers delivered events, instead of walking the caches on every scrape. Time based series then only advance on changes and resyncs`)
+
+	deltaMetrics = flags.Bool("delta-metrics", false, `Experimental: if true, serve the series of the pods, deployments, nodes and replication controllers changed since the last scrape on `+deltaPath)
+
+	userAgent = flags.String("user-agent", "domeos-agent/"+Version, `User agent the agent identifies itself with to the apiserver, e.g. in audit logs`)
+
+	insecureSkipTLSVerify = flags.Bool("insecure-skip-tls-verify", false, `If true, the apiserver's certificate will not be checked for validity. This makes the connection insecure; do not use in production`)
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run starts the agent and returns the exit code once it has to stop. Every
+// failure is logged by shutdown with its reason.
+func run() int {
 	flags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flags.PrintDefaults()
@@ -65,26 +172,86 @@ func main() {
 
 	err := flags.Parse(os.Args)
 	if err != nil {
-		glog.Fatalf("Error: %s", err)
+		return shutdown(exitConfigError, err)
 	}
 
 	if *help {
 		flags.Usage()
-		os.Exit(0)
+		return 0
 	}
 
 	if *apiserver == "" && !(*inCluster) {
-		glog.Fatalf("--apiserver not set and --in-cluster is false; apiserver must be set to a valid URL")
+		return shutdown(exitConfigError, errors.New("--apiserver not set and --in-cluster is false; apiserver must be set to a valid URL"))
+	}
+	if *apiserver != "" {
+		if err := validateApiServerURL(*apiserver); err != nil {
+			return shutdown(exitConfigError, fmt.Errorf("invalid --apiserver %q: %s", *apiserver, err))
+		}
+	}
+	if (*metricsBasicAuthUser == "") != (*metricsBasicAuthPassword == "") {
+		return shutdown(exitConfigError, errors.New("--metrics-basic-auth-user and --metrics-basic-auth-password must be set together"))
+	}
+	if _, err := parseCriticalDeployments(*criticalDeployments); err != nil {
+		return shutdown(exitConfigError, fmt.Errorf("invalid --critical-deployments: %s", err))
+	}
+	if *owner != "" {
+		if ownerTarget, err = parseOwner(*owner); err != nil {
+			return shutdown(exitConfigError, fmt.Errorf("invalid --owner: %s", err))
+		}
 	}
 	glog.Infof("apiServer set to: %v", *apiserver)
+	if *helpOverrides != "" {
+		if err := loadHelpOverrides(*helpOverrides); err != nil {
+			return shutdown(exitConfigError, fmt.Errorf("invalid --help-overrides: %s", err))
+		}
+	}
+	if *resourceVersionFile != "" {
+		persistedVersions = loadResourceVersions(*resourceVersionFile)
+	}
+	if *relabelConfigFile != "" {
+		if relabelConfigs, err = loadRelabelConfigs(*relabelConfigFile); err != nil {
+			return shutdown(exitConfigError, fmt.Errorf("invalid --relabel-config: %s", err))
+		}
+	}
 
 	kubeClient, err := CreateKubeClient(*apiserver)
 	if err != nil {
-		glog.Fatalf("Failed to create client: %v", err)
+		reason := exitConfigError
+		if _, ok := err.(unreachableError); ok {
+			reason = exitAPIServerUnreachable
+		}
+		return shutdown(reason, fmt.Errorf("failed to create client: %v", err))
+	}
+
+	if *kubeconfigReloadInterval > 0 && !*inCluster && flags.Changed("kubeconfig") {
+		r := &collectionRestarter{
+			newClient: func() (clientset.Interface, error) { return CreateKubeClient(*apiserver) },
+			start: func(c clientset.Interface, stopCh <-chan struct{}) *MetricCollection {
+				collection := startMetricCollection(c, prometheus.DefaultRegisterer, stopCh)
+				collection.publish()
+				return collection
+			},
+		}
+		r.restart(kubeClient)
+		go watchFile(*kubeconfig, *kubeconfigReloadInterval, r.reload, wait.NeverStop)
+	} else {
+		InitializeMetricCollection(kubeClient)
+	}
+	if *pushgatewayURL != "" {
+		go pushMetrics(*pushgatewayURL, *pushgatewayJob, *pushgatewayInterval, prometheus.DefaultGatherer, wait.NeverStop)
+	}
+	if *metricsFile != "" {
+		go exportMetricsFile(*metricsFile, *metricsFileInterval, prometheus.DefaultGatherer, wait.NeverStop)
 	}
 
-	InitializeMetricCollection(kubeClient)
-	metricsServer()
+	// Both servers run until they fail; the first failure stops the agent.
+	errCh := make(chan error, 2)
+	if *secondaryPort != 0 {
+		go func() { errCh <- secondaryMetricsServer() }()
+	}
+	go func() { errCh <- metricsServer() }()
+	err = <-errCh
+	return shutdown(exitReasonOf(err), err)
 }
 
 func CreateKubeClient(strApiServer string) (kubeClient clientset.Interface, err error) {
@@ -105,6 +272,7 @@ func CreateKubeClient(strApiServer string) (kubeClient clientset.Interface, err
 		}
 		glog.Infof("service account token present: %v", tokenPresent)
 		glog.Infof("service host: %s", config.Host)
+		config.UserAgent = *userAgent
 		if kubeClient, err = clientset.NewForConfig(config); err != nil {
 			return nil, err
 		}
@@ -116,60 +284,196 @@ func CreateKubeClient(strApiServer string) (kubeClient clientset.Interface, err
 		// kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
 		// config, err := kubeConfig.ClientConfig()
 		//config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
-		config, err := clientcmd.DefaultClientConfig.ClientConfig()
+		var config *restclient.Config
+		if flags.Changed("kubeconfig") {
+			config, err = clientcmd.BuildConfigFromFlags(strApiServer, *kubeconfig)
+		} else {
+			config, err = clientcmd.DefaultClientConfig.ClientConfig()
+		}
 		if err != nil {
 			return nil, err
 		}
 		config.Host = strApiServer
+		config.UserAgent = *userAgent
+		setInsecureSkipTLSVerify(config, *insecureSkipTLSVerify)
 		kubeClient, err = clientset.NewForConfig(config)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if kubeClient, err = withDiscoveryConfig(kubeClient, strApiServer, *discoveryKubeconfig); err != nil {
+		return nil, fmt.Errorf("creating discovery client from --discovery-kubeconfig failed: %v", err)
+	}
+
 	// Informers don't seem to do a good job logging error messages when it
 	// can't reach the server, making debugging hard. This makes it easier to
 	// figure out if apiserver is configured incorrectly.
 	glog.Infof("testing communication with server")
-	_, err = kubeClient.Discovery().ServerVersion()
+	err = checkServerVersion(kubeClient.Discovery(), *apiserverTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("ERROR communicating with apiserver: %v", err)
+		return nil, unreachableError{fmt.Errorf("ERROR communicating with apiserver: %v", err)}
 	}
 
 	return kubeClient, nil
 }
 
+// checkServerVersion asks the apiserver for its version and gives up after
+// timeout, so that an unreachable apiserver fails startup instead of hanging.
+func checkServerVersion(d discovery.ServerVersionInterface, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.ServerVersion()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// Gather gathers the metrics of the default registry.
 func Gather() ([]*dto.MetricFamily, error) {
-	mfs, err := prometheus.DefaultGatherer.Gather()
-	return mfs, err
+	return GatherFrom(prometheus.DefaultGatherer)
 }
 
-func metricsServer() {
+// GatherFrom gathers the metrics of g, e.g. the registry passed to
+// InitializeMetricCollectionFor.
+func GatherFrom(g prometheus.Gatherer) ([]*dto.MetricFamily, error) {
+	return g.Gather()
+}
+
+// metricsServer serves the metrics until the server fails.
+func metricsServer() error {
 	// Address to listen on for web interface and telemetry
 	listenAddress := fmt.Sprintf(":%d", *port)
 
 	glog.Infof("Starting metrics server: %s", listenAddress)
+	prefix := normalizeRoutePrefix(*routePrefix)
+	handler := maxInFlight(*maxRequestsInFlight, newMetricsMux(prefix), prefix+healthzPath)
+	if *jsonErrorResponses {
+		handler = jsonErrors(handler)
+	}
+	srv := newMetricsServer(listenAddress, handler)
+	return listenAndServe(srv)
+}
+
+var (
+	extraHandlersLock sync.Mutex
+	extraHandlers     = map[string]http.Handler{}
+)
+
+// RegisterHandler adds handler for pattern to the mux of the metrics server,
+// next to the default routes. It must be called before the server starts.
+// The patterns of the default routes cannot be registered.
+func RegisterHandler(pattern string, handler http.Handler) error {
+	switch pattern {
+	case metricsPath, healthzPath, "/":
+		return fmt.Errorf("pattern %s is served by the metrics server already", pattern)
+	}
+	extraHandlersLock.Lock()
+	defer extraHandlersLock.Unlock()
+	extraHandlers[pattern] = handler
+	return nil
+}
+
+// newMetricsMux returns the mux with the default routes of the metrics
+// server and the handlers added by RegisterHandler, all below prefix.
+func newMetricsMux(prefix string) http.Handler {
+	metrics := metricsHandler(*instrumentMetricsHandler, prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
+	if *metricsRequireSync {
+		metrics = requireSynced(informersSynced, metrics)
+	}
+	extraHandlersLock.Lock()
+	patterns := make([]string, 0, len(extraHandlers))
+	for pattern := range extraHandlers {
+		patterns = append(patterns, pattern)
+	}
+	extraHandlersLock.Unlock()
+	// Look the handlers up per request, as restarting the metric collection
+	// registers new ones.
+	return buildMetricsMux(prefix, metrics, patterns, func(pattern string) http.Handler {
+		extraHandlersLock.Lock()
+		defer extraHandlersLock.Unlock()
+		return extraHandlers[pattern]
+	})
+}
+
+// buildMetricsMux returns the mux serving metrics on metricsPath next to
+// healthzPath, the index and the handlers of patterns, which handler looks
+// up per request, all below prefix.
+func buildMetricsMux(prefix string, metrics http.Handler, patterns []string, handler func(pattern string) http.Handler) http.Handler {
+	mux := http.NewServeMux()
 	// Add metricsPath
-	http.Handle(metricsPath, prometheus.UninstrumentedHandler())
+	mux.Handle(metricsPath, metrics)
 	// Add healthzPath
-	http.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 		w.Write([]byte("ok"))
 	})
 	// Add index
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Kube Metrics Server</title></head>
              <body>
              <h1>Kube Metrics</h1>
 			 <ul>
-             <li><a href='` + metricsPath + `'>metrics</a></li>
-             <li><a href='` + healthzPath + `'>healthz</a></li>
+             <li><a href='` + prefix + metricsPath + `'>metrics</a></li>
+             <li><a href='` + prefix + healthzPath + `'>healthz</a></li>
 			 </ul>
              </body>
              </html>`))
 	})
-	log.Fatal(http.ListenAndServe(listenAddress, nil))
+
+	for _, pattern := range patterns {
+		pattern := pattern
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			handler(pattern).ServeHTTP(w, r)
+		})
+	}
+	return withRoutePrefix(prefix, requireBasicAuth(mux))
+}
+
+// secondaryMetricsServer serves the subset of metrics selected by
+// --secondary-metrics on --secondary-port, e.g. to let a different
+// Prometheus scrape the application metrics only. It returns once the server
+// fails.
+func secondaryMetricsServer() error {
+	listenAddress := fmt.Sprintf(":%d", *secondaryPort)
+
+	glog.Infof("Starting secondary metrics server: %s serving %v", listenAddress, *secondaryMetrics)
+	mux := newSubsetMux(prometheus.DefaultGatherer, *secondaryMetrics)
+	srv := newMetricsServer(listenAddress, withRoutePrefix(normalizeRoutePrefix(*routePrefix), requireBasicAuth(mux)))
+	return listenAndServe(srv)
+}
+
+// newSubsetMux returns the mux serving the metrics of gatherer whose names
+// start with one of prefixes on metricsPath, next to healthzPath.
+func newSubsetMux(gatherer prometheus.Gatherer, prefixes []string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(prefixGatherer{gatherer, prefixes}, promhttp.HandlerOpts{}))
+	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+// newMetricsServer returns the server for the metrics endpoint with the
+// timeouts from the flags, so slow or idle clients cannot hold connections
+// forever while scrapes keep reusing theirs.
+func newMetricsServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:           addr,
+		Handler:        handler,
+		ReadTimeout:    *serverReadTimeout,
+		WriteTimeout:   *serverWriteTimeout,
+		IdleTimeout:    *serverIdleTimeout,
+		MaxHeaderBytes: *serverMaxHeaderBytes,
+	}
 }
 
 type DeploymentLister func() ([]v1beta1.Deployment, error)
@@ -196,21 +500,207 @@ func (l RCLister) List() ([]v1.ReplicationController, error) {
 	return l()
 }
 
-// initializeMetricCollection creates and starts informers and initializes and
-// registers metrics for collection.
+type JobLister func() ([]batchv1.Job, error)
+
+func (l JobLister) List() ([]batchv1.Job, error) {
+	return l()
+}
+
+type PDBLister func() ([]policy.PodDisruptionBudget, error)
+
+func (l PDBLister) List() ([]policy.PodDisruptionBudget, error) {
+	return l()
+}
+
+type ServiceAccountLister func() ([]v1.ServiceAccount, error)
+
+func (l ServiceAccountLister) List() ([]v1.ServiceAccount, error) {
+	return l()
+}
+
+type ComponentStatusLister func() ([]v1.ComponentStatus, error)
+
+func (l ComponentStatusLister) List() ([]v1.ComponentStatus, error) {
+	return l()
+}
+
+type EndpointSliceLister func() ([]endpointSlice, error)
+
+func (l EndpointSliceLister) List() ([]endpointSlice, error) {
+	return l()
+}
+
+type LeaseLister func() ([]lease, error)
+
+func (l LeaseLister) List() ([]lease, error) {
+	return l()
+}
+
+type IngressLister func() ([]ingress, error)
+
+func (l IngressLister) List() ([]ingress, error) {
+	return l()
+}
+
+// pollComponentStatuses lists component statuses every
+// componentStatusPollPeriod and returns a lister over the latest result. If
+// the API is unavailable the lister stays empty, and once the server answers
+// NotFound, as after the API was removed, polling stops.
+func pollComponentStatuses(kubeClient clientset.Interface, period time.Duration, stopCh <-chan struct{}) ComponentStatusLister {
+	var (
+		mu  sync.RWMutex
+		css []v1.ComponentStatus
+	)
+	go func() {
+		for {
+			l, err := kubeClient.Core().ComponentStatuses().List(v1.ListOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+				glog.Infof("component statuses are not served, no longer polling them")
+				return
+			case err != nil:
+				glog.Warningf("listing component statuses failed: %s", err)
+			default:
+				mu.Lock()
+				css = l.Items
+				mu.Unlock()
+			}
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(period):
+			}
+		}
+	}()
+
+	return ComponentStatusLister(func() ([]v1.ComponentStatus, error) {
+		mu.RLock()
+		defer mu.RUnlock()
+		return css, nil
+	})
+}
+
+// sortedStrings returns a sorted copy of ss.
+func sortedStrings(ss []string) []string {
+	sorted := append([]string(nil), ss...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// namespaceFilter holds the namespaces metrics are emitted for. An empty
+// filter allows every namespace.
+type namespaceFilter map[string]bool
+
+func newNamespaceFilter(namespaces []string) namespaceFilter {
+	f := namespaceFilter{}
+	for _, ns := range namespaces {
+		if ns != "" {
+			f[ns] = true
+		}
+	}
+	return f
+}
+
+func (f namespaceFilter) allowed(namespace string) bool {
+	return len(f) == 0 || f[namespace]
+}
+
+func stringSet(ss []string) map[string]bool {
+	set := map[string]bool{}
+	for _, s := range ss {
+		set[s] = true
+	}
+	return set
+}
+
+// initializedCollections are the collections started by
+// InitializeMetricCollectionFor per registerer.
+var initializedCollections struct {
+	sync.Mutex
+	byRegisterer map[prometheus.Registerer]*MetricCollection
+}
+
+// InitializeMetricCollection creates and starts informers and initializes and
+// registers metrics for collection with the default registry, served by the
+// metrics server of the agent. Calls after the first do nothing.
 func InitializeMetricCollection(kubeClient clientset.Interface) {
+	if c, started := initializeMetricCollection(kubeClient, prometheus.DefaultRegisterer); started {
+		c.publish()
+	}
+}
+
+// InitializeMetricCollectionFor is like InitializeMetricCollection but
+// registers the metrics with r, so that several clusters can be collected
+// into separate registries. The returned collection serves them with its
+// own routes, see MetricCollection.Handler. Calls with a registerer used
+// before return the collection started for it.
+func InitializeMetricCollectionFor(kubeClient clientset.Interface, r prometheus.Registerer) *MetricCollection {
+	c, _ := initializeMetricCollection(kubeClient, r)
+	return c
+}
+
+// initializeMetricCollection starts the collection for r unless one runs
+// already, and reports whether it started one.
+func initializeMetricCollection(kubeClient clientset.Interface, r prometheus.Registerer) (*MetricCollection, bool) {
+	initializedCollections.Lock()
+	defer initializedCollections.Unlock()
+	if c, ok := initializedCollections.byRegisterer[r]; ok {
+		glog.Warningf("metric collection initialized already, keeping the running one")
+		return c, false
+	}
+	if initializedCollections.byRegisterer == nil {
+		initializedCollections.byRegisterer = map[prometheus.Registerer]*MetricCollection{}
+	}
+	c := startMetricCollection(kubeClient, r, wait.NeverStop)
+	initializedCollections.byRegisterer[r] = c
+	return c, true
+}
+
+// startMetricCollection runs the informers for kubeClient until stopCh is
+// closed and registers the collectors reading them with r. The collectors are
+// registered through the registerer of the returned collection, to be
+// unregistered when the collection is restarted.
+func startMetricCollection(kubeClient clientset.Interface, r prometheus.Registerer, stopCh <-chan struct{}) *MetricCollection {
+	reg := &trackingRegisterer{Registerer: r}
+	metrics := newCollectionMetrics()
+	handlers := map[string]http.Handler{}
 	cclient := kubeClient.Core().RESTClient()
 	eclient := kubeClient.Extensions().RESTClient()
+	bclient := kubeClient.Batch().RESTClient()
 
-	dlw := cache.NewListWatchFromClient(eclient, "deployments", api.NamespaceAll, nil)
-	plw := cache.NewListWatchFromClient(cclient, "pods", api.NamespaceAll, nil)
-	nlw := cache.NewListWatchFromClient(cclient, "nodes", api.NamespaceAll, nil)
-	rlw := cache.NewListWatchFromClient(cclient, "replicationcontrollers", api.NamespaceAll, nil)
+	dd := metrics.degradation("deployments")
+	pd := metrics.degradation("pods")
+	nd := metrics.degradation("nodes")
+	rd := metrics.degradation("replicationcontrollers")
+	jd := metrics.degradation("jobs")
 
-	dinf := cache.NewSharedInformer(dlw, &v1beta1.Deployment{}, resyncPeriod)
-	pinf := cache.NewSharedInformer(plw, &v1.Pod{}, resyncPeriod)
-	ninf := cache.NewSharedInformer(nlw, &v1.Node{}, resyncPeriod)
-	rinf := cache.NewSharedInformer(rlw, &v1.ReplicationController{}, resyncPeriod)
+	dlw := trackListWatch(newListWatch(eclient, "deployments", func() runtime.Object { return &v1beta1.DeploymentList{} }), dd)
+	plw := trackListWatch(newListWatch(cclient, "pods", func() runtime.Object { return &v1.PodList{} }), pd)
+	nlw := trackListWatch(newListWatch(cclient, "nodes", func() runtime.Object { return &v1.NodeList{} }), nd)
+	rlw := trackListWatch(newListWatch(cclient, "replicationcontrollers", func() runtime.Object { return &v1.ReplicationControllerList{} }), rd)
+	jlw := trackListWatch(newListWatch(bclient, "jobs", func() runtime.Object { return &batchv1.JobList{} }), jd)
+	transform := cacheTransform(*stripAnnotations)
+	dlw = transformListWatch(dlw, transform)
+	plw = transformListWatch(plw, transform)
+	nlw = transformListWatch(nlw, transform)
+	rlw = transformListWatch(rlw, transform)
+	jlw = transformListWatch(jlw, transform)
+
+	dinf := newWatchedInformer("deployments", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+		return cache.NewSharedInformer(dlw, &v1beta1.Deployment{}, resyncPeriod)
+	})
+	pinf := newWatchedInformer("pods", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+		return cache.NewSharedInformer(plw, &v1.Pod{}, resyncPeriod)
+	})
+	ninf := newWatchedInformer("nodes", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+		return cache.NewSharedInformer(nlw, &v1.Node{}, resyncPeriod)
+	})
+	rinf := newWatchedInformer("replicationcontrollers", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+		return cache.NewSharedInformer(rlw, &v1.ReplicationController{}, resyncPeriod)
+	})
+	jinf := newWatchedInformer("jobs", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+		return cache.NewSharedInformer(jlw, &batchv1.Job{}, resyncPeriod)
+	})
 
 	dplLister := DeploymentLister(func() (deployments []v1beta1.Deployment, err error) {
 		for _, c := range dinf.GetStore().List() {
@@ -240,15 +730,341 @@ func InitializeMetricCollection(kubeClient clientset.Interface) {
 		return rcs, nil
 	})
 
-	prometheus.MustRegister(&deploymentCollector{store: dplLister})
-	prometheus.MustRegister(&podCollector{store: podLister})
-	prometheus.MustRegister(&nodeCollector{store: nodeLister})
-	prometheus.MustRegister(&replicationcontrollerCollector{store: rcLister})
+	jobLister := JobLister(func() (jobs []batchv1.Job, err error) {
+		for _, m := range jinf.GetStore().List() {
+			jobs = append(jobs, *m.(*batchv1.Job))
+		}
+		return jobs, nil
+	})
+
+	namespaces := newNamespaceFilter(*metricNamespaces)
+
+	var podOwner *ownerFilter
+	if target := ownerTarget; target != nil {
+		// Pods of a deployment are owned through its ReplicaSets, which are
+		// only watched when walking owners.
+		rslw := cache.NewListWatchFromClient(eclient, "replicasets", target.namespace, nil)
+		rsinf := cache.NewSharedInformer(rslw, &v1beta1.ReplicaSet{}, resyncPeriod)
+		go rsinf.Run(stopCh)
+		podOwner = &ownerFilter{
+			target: target,
+			lookup: func(kind, namespace, name string) (v1.ObjectMeta, bool) {
+				if kind != "ReplicaSet" {
+					return v1.ObjectMeta{}, false
+				}
+				obj, ok, err := rsinf.GetStore().GetByKey(namespace + "/" + name)
+				if err != nil || !ok {
+					return v1.ObjectMeta{}, false
+				}
+				return obj.(*v1beta1.ReplicaSet).ObjectMeta, true
+			},
+		}
+	}
+
+	// run validated the flag already; embedders get no pinned deployments
+	// for invalid ones.
+	pinned, err := parseCriticalDeployments(*criticalDeployments)
+	if err != nil {
+		glog.Errorf("Invalid --critical-deployments: %v", err)
+	}
 
-	go dinf.Run(context.Background().Done())
-	go pinf.Run(context.Background().Done())
-	go ninf.Run(context.Background().Done())
-	go rinf.Run(context.Background().Done())
+	// snapshot serves c from snapshots rebuilt on the events of infs with
+	// --snapshot-debounce.
+	snapshot := func(c prometheus.Collector, infs ...cache.SharedInformer) prometheus.Collector {
+		if *snapshotDebounce <= 0 {
+			return c
+		}
+		sc := newSnapshotCollector(c, *snapshotDebounce)
+		for _, inf := range infs {
+			inf.AddEventHandler(sc.handler())
+		}
+		return sc
+	}
+
+	collectors := newCollectorSwitch(reg, metrics.collectorRegistered)
+	collectors.add("deployments", &degradableCollector{
+		Collector:   snapshot(&deploymentCollector{store: dplLister, namespaces: namespaces}, dinf),
+		degradation: dd,
+	})
+	collectors.add("criticaldeployments", &degradableCollector{
+		Collector:   &criticalDeploymentCollector{store: dplLister, pinned: pinned},
+		degradation: dd,
+	})
+	pc := &podCollector{
+		store:            podLister,
+		namespaces:       namespaces,
+		owner:            podOwner,
+		nodeSelectorKeys: stringSet(*nodeSelectorKeys),
+		labelHashKeys:    sortedStrings(*labelHashKeys),
+		restartThreshold: *podRestartThreshold,
+	}
+	collectors.add("pods", &degradableCollector{
+		Collector:   snapshot(pc, pinf),
+		degradation: pd,
+	})
+	handlers[podMetricsPath] = podMetricsHandler(*pc, func(namespace, name string) (v1.Pod, bool) {
+		obj, ok, err := pinf.GetStore().GetByKey(namespace + "/" + name)
+		if err != nil || !ok {
+			return v1.Pod{}, false
+		}
+		return *obj.(*v1.Pod), true
+	})
+	collectors.add("nodes", &degradableCollector{
+		Collector:   snapshot(&nodeCollector{store: nodeLister}, ninf),
+		degradation: nd,
+	})
+	collectors.add("nodeallocation", &nodeAllocationCollector{nodes: nodeLister, pods: podLister})
+	collectors.add("replicationcontrollers", &degradableCollector{
+		Collector:   snapshot(&replicationcontrollerCollector{store: rcLister, namespaces: namespaces}, rinf),
+		degradation: rd,
+	})
+	collectors.add("jobs", &degradableCollector{
+		Collector:   &jobCollector{store: jobLister, namespaces: namespaces},
+		degradation: jd,
+	})
+	collectors.add("componentstatuses", &componentstatusCollector{store: pollComponentStatuses(kubeClient, componentStatusPollPeriod, stopCh)})
+	register(reg, metrics.collectors()...)
+	register(reg, newBuildInfo())
+
+	// Endpoint slices and node leases are not known to the vendored
+	// client-go, so they are listed raw into caches refreshed every
+	// --raw-list-period.
+	escache := &rawListCache{
+		resource:      "endpointslices",
+		groupVersions: endpointSliceGroupVersions,
+		discovery:     kubeClient.Discovery(),
+		list: func(gv string) (interface{}, error) {
+			var l endpointSliceList
+			err := getRaw(cclient, &l, "/apis", gv, "endpointslices")
+			return l.Items, err
+		},
+	}
+	go escache.run(*rawListPeriod, stopCh)
+	collectors.add("endpointslices", &endpointsliceCollector{
+		store: EndpointSliceLister(func() ([]endpointSlice, error) {
+			slices, _ := escache.get().([]endpointSlice)
+			return slices, nil
+		}),
+		namespaces: namespaces,
+	})
+
+	leasecache := &rawListCache{
+		resource:      "leases",
+		groupVersions: leaseGroupVersions,
+		discovery:     kubeClient.Discovery(),
+		list: func(gv string) (interface{}, error) {
+			var l leaseList
+			err := getRaw(cclient, &l, "/apis", gv, "namespaces", nodeLeaseNamespace, "leases")
+			return l.Items, err
+		},
+	}
+	go leasecache.run(*rawListPeriod, stopCh)
+	collectors.add("nodeleases", &nodeLeaseCollector{
+		store: LeaseLister(func() ([]lease, error) {
+			leases, _ := leasecache.get().([]lease)
+			return leases, nil
+		}),
+	})
+	// Ingresses are listed raw as well, to read networking.k8s.io/v1 where
+	// extensions/v1beta1 is no longer served.
+	ingcache := &rawListCache{
+		resource:      "ingresses",
+		groupVersions: ingressGroupVersions,
+		discovery:     kubeClient.Discovery(),
+		list: func(gv string) (interface{}, error) {
+			var l ingressList
+			err := getRaw(cclient, &l, "/apis", gv, "ingresses")
+			return l.Items, err
+		},
+	}
+	go ingcache.run(*rawListPeriod, stopCh)
+	collectors.add("ingresses", &ingressCollector{
+		store: IngressLister(func() ([]ingress, error) {
+			ingresses, _ := ingcache.get().([]ingress)
+			return ingresses, nil
+		}),
+		namespaces: namespaces,
+	})
+	registerSelfCollectors(r)
+
+	informers := map[string]cache.SharedInformer{
+		"deployments":            dinf,
+		"pods":                   pinf,
+		"nodes":                  ninf,
+		"replicationcontrollers": rinf,
+		"jobs":                   jinf,
+	}
+
+	// Service accounts are only watched with --serviceaccount-metrics, as
+	// the cluster-wide watch needs RBAC access the other collectors do not.
+	if *serviceaccountMetrics {
+		sad := metrics.degradation("serviceaccounts")
+		salw := trackListWatch(newListWatch(cclient, "serviceaccounts", func() runtime.Object { return &v1.ServiceAccountList{} }), sad)
+		salw = transformListWatch(salw, transform)
+		sainf := newWatchedInformer("serviceaccounts", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+			return cache.NewSharedInformer(salw, &v1.ServiceAccount{}, resyncPeriod)
+		})
+		saLister := ServiceAccountLister(func() (sas []v1.ServiceAccount, err error) {
+			for _, m := range sainf.GetStore().List() {
+				sas = append(sas, *m.(*v1.ServiceAccount))
+			}
+			return sas, nil
+		})
+		collectors.add("serviceaccounts", &degradableCollector{
+			Collector:   &serviceaccountCollector{store: saLister, namespaces: namespaces},
+			degradation: sad,
+		})
+		informers["serviceaccounts"] = sainf
+	} else {
+		collectors.skip("serviceaccounts")
+	}
+
+	// Pod disruption budgets are only watched where the policy group is
+	// served.
+	if _, err := servedGroupVersion(kubeClient.Discovery(), "poddisruptionbudgets", pdbGroupVersions); err != nil {
+		glog.Infof("not collecting pod disruption budgets: %v", err)
+		collectors.skip("poddisruptionbudgets")
+	} else {
+		pdbd := metrics.degradation("poddisruptionbudgets")
+		pdblw := trackListWatch(newListWatch(kubeClient.Policy().RESTClient(), "poddisruptionbudgets", func() runtime.Object { return &policy.PodDisruptionBudgetList{} }), pdbd)
+		pdblw = transformListWatch(pdblw, transform)
+		pdbinf := newWatchedInformer("poddisruptionbudgets", *informerSyncDeadline, metrics.informerRebuilds, func() cache.SharedInformer {
+			return cache.NewSharedInformer(pdblw, &policy.PodDisruptionBudget{}, resyncPeriod)
+		})
+		pdbLister := PDBLister(func() (pdbs []policy.PodDisruptionBudget, err error) {
+			for _, m := range pdbinf.GetStore().List() {
+				pdbs = append(pdbs, *m.(*policy.PodDisruptionBudget))
+			}
+			return pdbs, nil
+		})
+		collectors.add("poddisruptionbudgets", &degradableCollector{
+			Collector:   &pdbCollector{store: pdbLister, namespaces: namespaces},
+			degradation: pdbd,
+		})
+		informers["poddisruptionbudgets"] = pdbinf
+	}
+	dinf.AddEventHandler(scaleHandler(metrics.workloadScaleEvents))
+	rinf.AddEventHandler(scaleHandler(metrics.workloadScaleEvents))
+
+	if *deltaMetrics {
+		handlers[deltaPath] = newDeltaHandler(dinf, pinf, ninf, rinf, namespaces, podOwner)
+	}
+
+	states := make(map[string]informerState, len(informers))
+	for resource, inf := range informers {
+		states[resource] = inf
+	}
+	collection := &MetricCollection{reg: reg, metrics: metrics, informers: states, handlers: handlers}
+	register(reg, &healthCollector{synced: collection.Synced, errors: metrics.listWatchErrors, window: *healthWatchErrorWindow})
+	handlers["/admin/informers"] = informersHandler(states)
+	handlers[collectorsPath] = collectors
+
+	for resource, inf := range informers {
+		inf.AddEventHandler(syncHandler(resource, metrics.informerLastSync))
+		inf.AddEventHandler(eventCounter(resource, metrics.informerEvents))
+		go inf.Run(stopCh)
+		go func(resource string, inf cache.SharedInformer) {
+			if cache.WaitForCacheSync(stopCh, inf.HasSynced) {
+				markSynced(resource, metrics.informerLastSync)
+			}
+		}(resource, inf)
+	}
+	if persistedVersions != nil {
+		go wait.Until(func() { saveResourceVersions(persistedVersions, informers) }, resourceVersionSavePeriod, stopCh)
+	}
+	return collection
+}
+
+// newDeltaHandler tracks the changes seen by the informers and returns the
+// handler serving the series of the changed objects on deltaPath.
+func newDeltaHandler(dinf, pinf, ninf, rinf cache.SharedInformer, namespaces namespaceFilter, podOwner *ownerFilter) http.Handler {
+	dt, pt, nt, rt := newChangeTracker(), newChangeTracker(), newChangeTracker(), newChangeTracker()
+	dinf.AddEventHandler(dt.handler())
+	pinf.AddEventHandler(pt.handler())
+	ninf.AddEventHandler(nt.handler())
+	rinf.AddEventHandler(rt.handler())
+
+	return deltaHandler(func() []prometheus.Collector {
+		var deployments []v1beta1.Deployment
+		for _, m := range changedObjects(dinf.GetStore(), dt.take()) {
+			deployments = append(deployments, *m.(*v1beta1.Deployment))
+		}
+		var pods []v1.Pod
+		for _, m := range changedObjects(pinf.GetStore(), pt.take()) {
+			pods = append(pods, *m.(*v1.Pod))
+		}
+		var nodes v1.NodeList
+		for _, m := range changedObjects(ninf.GetStore(), nt.take()) {
+			nodes.Items = append(nodes.Items, *m.(*v1.Node))
+		}
+		var rcs []v1.ReplicationController
+		for _, m := range changedObjects(rinf.GetStore(), rt.take()) {
+			rcs = append(rcs, *m.(*v1.ReplicationController))
+		}
+		return []prometheus.Collector{
+			&deploymentCollector{store: DeploymentLister(func() ([]v1beta1.Deployment, error) { return deployments, nil }), namespaces: namespaces},
+			&podCollector{store: PodLister(func() ([]v1.Pod, error) { return pods, nil }), namespaces: namespaces, owner: podOwner, nodeSelectorKeys: stringSet(*nodeSelectorKeys), labelHashKeys: sortedStrings(*labelHashKeys), restartThreshold: *podRestartThreshold},
+			&nodeCollector{store: NodeLister(func() (v1.NodeList, error) { return nodes, nil })},
+			&replicationcontrollerCollector{store: RCLister(func() ([]v1.ReplicationController, error) { return rcs, nil }), namespaces: namespaces},
+		}
+	})
+}
+
+// register registers each of cs with r. Unlike MustRegister it does not
+// panic if the metric collection is initialized twice, but keeps the
+// collectors registered first.
+func register(r prometheus.Registerer, cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				glog.Warningf("collector already registered, keeping the registered one")
+			} else {
+				glog.Errorf("registering collector failed: %s", err)
+			}
+		}
+	}
+}
+
+// registerSelfCollectors registers the process and Go runtime collectors so the
+// agent's own memory, GC and goroutine metrics are exposed. The default
+// registry already carries both, so AlreadyRegisteredError is not an error.
+func registerSelfCollectors(r prometheus.Registerer) {
+	for _, c := range []prometheus.Collector{
+		prometheus.NewProcessCollector(os.Getpid(), ""),
+		prometheus.NewGoCollector(),
+	} {
+		if err := r.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				glog.Errorf("registering self collector failed: %s", err)
+			}
+		}
+	}
+}
+
+// validateApiServerURL checks that s is an http or https URL with a host, so
+// a malformed --apiserver fails at startup rather than at the first request.
+func validateApiServerURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host missing")
+	}
+	return nil
+}
+
+// setInsecureSkipTLSVerify disables verification of the apiserver's
+// certificate in config when insecure is set.
+func setInsecureSkipTLSVerify(config *restclient.Config, insecure bool) {
+	if !insecure {
+		return
+	}
+	glog.Warningf("WARNING: TLS verification of the apiserver at %s is disabled, the connection is insecure. Never use --insecure-skip-tls-verify in production!", config.Host)
+	config.Insecure = true
 }
 
 func SetApiServer(apiservertmp string) {
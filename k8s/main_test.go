@@ -0,0 +1,269 @@
+package k8s
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/version"
+	restclient "k8s.io/client-go/rest"
+)
+
+func hasMetricFamily(mfs []*dto.MetricFamily, name string) bool {
+	for _, mf := range mfs {
+		if mf.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSelfCollectors(t *testing.T) {
+	registerSelfCollectors(prometheus.DefaultRegisterer)
+	mfs, err := Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %s", err)
+	}
+	if !hasMetricFamily(mfs, "go_goroutines") {
+		t.Errorf("go_goroutines missing from default gatherer")
+	}
+
+	reg := prometheus.NewRegistry()
+	registerSelfCollectors(reg)
+	mfs, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %s", err)
+	}
+	if !hasMetricFamily(mfs, "go_goroutines") {
+		t.Errorf("go_goroutines missing from fresh registry")
+	}
+}
+
+// gatherFrom registers c with a fresh registry and gathers its metrics.
+func gatherFrom(t *testing.T, c prometheus.Collector) []*dto.MetricFamily {
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("registering collector failed: %s", err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %s", err)
+	}
+	return mfs
+}
+
+// labelValue returns the value of label name on m, or "" if it is not set.
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestNamespaceFilter(t *testing.T) {
+	pods := []v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Namespace: "allowed", Name: "a"}},
+		{ObjectMeta: v1.ObjectMeta{Namespace: "other", Name: "b"}},
+	}
+	pc := &podCollector{
+		store:      PodLister(func() ([]v1.Pod, error) { return pods, nil }),
+		namespaces: newNamespaceFilter([]string{"allowed"}),
+	}
+
+	mfs := gatherFrom(t, pc)
+	if len(mfs) == 0 {
+		t.Fatal("expected series for the allowed namespace")
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			if ns := labelValue(m, "namespace"); ns != "allowed" {
+				t.Errorf("%s: unexpected series for namespace %q", mf.GetName(), ns)
+			}
+		}
+	}
+
+	if !newNamespaceFilter(nil).allowed("any") {
+		t.Errorf("empty filter must allow every namespace")
+	}
+}
+
+// blockingDiscovery never answers ServerVersion, like a black-holed apiserver.
+type blockingDiscovery struct {
+	block chan struct{}
+}
+
+func (d blockingDiscovery) ServerVersion() (*version.Info, error) {
+	<-d.block
+	return &version.Info{}, nil
+}
+
+func TestCheckServerVersionTimeout(t *testing.T) {
+	d := blockingDiscovery{block: make(chan struct{})}
+	defer close(d.block)
+
+	start := time.Now()
+	err := checkServerVersion(d, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("check took %s, expected to give up after the timeout", elapsed)
+	}
+}
+
+func TestSetInsecureSkipTLSVerify(t *testing.T) {
+	for _, insecure := range []bool{false, true} {
+		config := &restclient.Config{Host: "https://example.com"}
+		setInsecureSkipTLSVerify(config, insecure)
+		if config.Insecure != insecure {
+			t.Errorf("insecure=%v: expected Insecure %v, got %v", insecure, insecure, config.Insecure)
+		}
+	}
+	if *insecureSkipTLSVerify {
+		t.Errorf("--insecure-skip-tls-verify must default to false")
+	}
+}
+
+func TestCreateKubeClientUserAgent(t *testing.T) {
+	userAgents := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.UserAgent()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major": "1", "minor": "5"}`))
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, "apiVersion: v1\nkind: Config\nclusters:\n- name: c\n  cluster: {server: %q}\ncontexts:\n- name: c\n  context: {cluster: c}\ncurrent-context: c\n", srv.URL)
+	f.Close()
+
+	oldKubeconfig, oldUserAgent := *kubeconfig, *userAgent
+	defer func() { flags.Set("kubeconfig", oldKubeconfig); *userAgent = oldUserAgent }()
+	flags.Set("kubeconfig", f.Name())
+	*userAgent = "audit-test/1.0"
+
+	if _, err := CreateKubeClient(srv.URL); err != nil {
+		t.Fatalf("creating client failed: %s", err)
+	}
+	if ua := <-userAgents; ua != "audit-test/1.0" {
+		t.Errorf("expected the configured user agent, got %q", ua)
+	}
+}
+
+func TestValidateApiServerURL(t *testing.T) {
+	for _, s := range []string{"http://10.0.0.1:8080", "https://kubernetes.example.com"} {
+		if err := validateApiServerURL(s); err != nil {
+			t.Errorf("%s: unexpected error: %s", s, err)
+		}
+	}
+	for _, s := range []string{"not a url", "10.0.0.1:8080", "ftp://example.com", "http://", "http://%zz"} {
+		if err := validateApiServerURL(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestStartMetricCollectionTwice(t *testing.T) {
+	stopCh := make(chan struct{})
+	close(stopCh)
+	kubeClient := fake.NewSimpleClientset()
+
+	reg := prometheus.NewRegistry()
+	first := startMetricCollection(kubeClient, reg, stopCh)
+	second := startMetricCollection(kubeClient, reg, stopCh)
+
+	if len(first.reg.registered) == 0 {
+		t.Fatal("expected the first initialization to register collectors")
+	}
+	if len(second.reg.registered) != 0 {
+		t.Errorf("expected the second initialization to keep the collectors of the first, registered %d", len(second.reg.registered))
+	}
+}
+
+func TestMetricCollectionCustomRegistry(t *testing.T) {
+	stopCh := make(chan struct{})
+	close(stopCh)
+	kubeClient := fake.NewSimpleClientset()
+
+	first, second := prometheus.NewRegistry(), prometheus.NewRegistry()
+	a := startMetricCollection(kubeClient, first, stopCh)
+	if len(a.reg.registered) == 0 {
+		t.Fatal("expected collectors to be registered with the first registry")
+	}
+	b := startMetricCollection(kubeClient, second, stopCh)
+	if len(b.reg.registered) == 0 {
+		t.Fatal("expected collectors to be registered with the second registry independently")
+	}
+	a.metrics.informerRebuilds.WithLabelValues("pods").Inc()
+	if v := counterValue(t, b.metrics.informerRebuilds, "pods"); v != 0 {
+		t.Errorf("expected the collections not to share self metrics, got %v rebuilds in the second", v)
+	}
+	first.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_first_only", Help: "Registered in the first registry only."}))
+	second.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_second_only", Help: "Registered in the second registry only."}))
+
+	rec := httptest.NewRecorder()
+	a.Handler(first).ServeHTTP(rec, httptest.NewRequest("GET", metricsPath, nil))
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "test_first_only") || strings.Contains(body, "test_second_only") {
+		t.Errorf("expected the handler to serve the metrics of its own registry only, got %d %s", rec.Code, body)
+	}
+	rec = httptest.NewRecorder()
+	a.Handler(first).ServeHTTP(rec, httptest.NewRequest("GET", "/admin/informers", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 from the admin handler of the collection, got %d", rec.Code)
+	}
+	for _, reg := range []*prometheus.Registry{first, second} {
+		mfs, err := GatherFrom(reg)
+		if err != nil {
+			t.Fatalf("gathering failed: %s", err)
+		}
+		if !hasMetricFamily(mfs, "agent_build_info") {
+			t.Errorf("expected agent_build_info in the custom registry")
+		}
+	}
+}
+
+func TestServiceAccountsOptIn(t *testing.T) {
+	defer func(v bool) { *serviceaccountMetrics = v }(*serviceaccountMetrics)
+	stopCh := make(chan struct{})
+	close(stopCh)
+	kubeClient := fake.NewSimpleClientset()
+
+	if c := startMetricCollection(kubeClient, prometheus.NewRegistry(), stopCh); c.informers["serviceaccounts"] != nil {
+		t.Errorf("expected service accounts not to be watched by default")
+	}
+	*serviceaccountMetrics = true
+	if c := startMetricCollection(kubeClient, prometheus.NewRegistry(), stopCh); c.informers["serviceaccounts"] == nil {
+		t.Errorf("expected service accounts to be watched with --serviceaccount-metrics")
+	}
+}
+
+func TestInitializeMetricCollectionOnce(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	running := &MetricCollection{reg: &trackingRegisterer{Registerer: reg}}
+	initializedCollections.Lock()
+	initializedCollections.byRegisterer = map[prometheus.Registerer]*MetricCollection{reg: running}
+	initializedCollections.Unlock()
+	defer func() { initializedCollections.byRegisterer = nil }()
+
+	if c := InitializeMetricCollectionFor(fake.NewSimpleClientset(), reg); c != running {
+		t.Errorf("expected a repeated initialization to return the running collection")
+	}
+	if mfs, _ := reg.Gather(); len(mfs) != 0 {
+		t.Errorf("expected a repeated initialization not to register collectors, got %d families", len(mfs))
+	}
+}
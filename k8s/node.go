@@ -17,13 +17,16 @@ limitations under the License.
 package k8s
 
 import (
+	"strings"
+
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/types"
 )
 
 var (
-	descNodeInfo = prometheus.NewDesc(
+	descNodeInfo = newObjectDesc(
 		"kube_node_info",
 		"Information about a cluster node.",
 		[]string{
@@ -33,64 +36,117 @@ var (
 			"container_runtime_version",
 			"kubelet_version",
 			"kubeproxy_version",
-		}, nil,
+		},
+	)
+
+	descNodeRole = newObjectDesc(
+		"kube_node_role",
+		"The role of a cluster node, from its node-role.kubernetes.io/<role> labels.",
+		[]string{"node", "role"},
 	)
 
-	descNodeSpecUnschedulable = prometheus.NewDesc(
+	descNodeSpecTaint = newObjectDesc(
+		"kube_node_spec_taint",
+		"The taint of a cluster node.",
+		[]string{"node", "key", "value", "effect"},
+	)
+
+	descNodeSpecUnschedulable = newObjectDesc(
 		"kube_node_spec_unschedulable",
 		"Whether a node can schedule new pods.",
-		[]string{"node"}, nil,
+		[]string{"node"},
 	)
 
-	descNodeStatusReady = prometheus.NewDesc(
+	descNodeStatusReady = newObjectDesc(
 		"kube_node_status_ready",
 		"The ready status of a cluster node.",
-		[]string{"node", "condition"}, nil,
+		[]string{"node", "condition"},
 	)
-	descNodeStatusOutOfDisk = prometheus.NewDesc(
+	descNodeStatusOutOfDisk = newObjectDesc(
 		"kube_node_status_out_of_disk",
 		"Whether the node is out of disk space",
-		[]string{"node", "condition"}, nil,
+		[]string{"node", "condition"},
 	)
-	descNodeStatusPhase = prometheus.NewDesc(
+	descNodeStatusPhase = newObjectDesc(
 		"kube_node_status_phase",
 		"The phase the node is currently in.",
-		[]string{"node", "phase"}, nil,
+		[]string{"node", "phase"},
 	)
 
-	descNodeStatusCapacityPods = prometheus.NewDesc(
+	descNodeStatusCapacityPods = newObjectDesc(
 		"kube_node_status_capacity_pods",
 		"The total pod resources of the node.",
-		[]string{"node"}, nil,
+		[]string{"node"},
 	)
-	descNodeStatusCapacityCPU = prometheus.NewDesc(
+	descNodeStatusCapacityCPU = newObjectDesc(
 		"kube_node_status_capacity_cpu_cores",
 		"The total CPU resources of the node.",
-		[]string{"node"}, nil,
+		[]string{"node"},
 	)
-	descNodeStatusCapacityMemory = prometheus.NewDesc(
+	descNodeStatusCapacityMemory = newObjectDesc(
 		"kube_node_status_capacity_memory_bytes",
 		"The total memory resources of the node.",
-		[]string{"node"}, nil,
+		[]string{"node"},
 	)
 
-	descNodeStatusAllocatablePods = prometheus.NewDesc(
+	descNodeStatusAllocatablePods = newObjectDesc(
 		"kube_node_status_allocatable_pods",
 		"The pod resources of a node that are available for scheduling.",
-		[]string{"node"}, nil,
+		[]string{"node"},
 	)
-	descNodeStatusAllocatableCPU = prometheus.NewDesc(
+	descNodeStatusAllocatableCPU = newObjectDesc(
 		"kube_node_status_allocatable_cpu_cores",
 		"The CPU resources of a node that are available for scheduling.",
-		[]string{"node"}, nil,
+		[]string{"node"},
 	)
-	descNodeStatusAllocatableMemory = prometheus.NewDesc(
+	descNodeStatusAllocatableMemory = newObjectDesc(
 		"kube_node_status_allocatable_memory_bytes",
 		"The memory resources of a node that are available for scheduling.",
-		[]string{"node"}, nil,
+		[]string{"node"},
+	)
+
+	descNodeReadyButUnschedulable = newObjectDesc(
+		"kube_node_ready_but_unschedulable",
+		"Whether the node is ready but cordoned, e.g. left over from maintenance.",
+		[]string{"node"},
+	)
+
+	descNodeImagesCount = newObjectDesc(
+		"kube_node_images_count",
+		"The number of container images cached on the node.",
+		[]string{"node"},
+	)
+	descNodeImagesSize = newObjectDesc(
+		"kube_node_images_size_bytes",
+		"The total size of the container images cached on the node.",
+		[]string{"node"},
 	)
 )
 
+// nodeRolePrefix prefixes the labels naming a role of a node, e.g.
+// node-role.kubernetes.io/master.
+const nodeRolePrefix = "node-role.kubernetes.io/"
+
+// nodeRoles returns the roles named by the node-role.kubernetes.io/<role>
+// labels, and by the older kubernetes.io/role label if set.
+func nodeRoles(labels map[string]string) []string {
+	seen := map[string]bool{}
+	var roles []string
+	add := func(role string) {
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	for k := range labels {
+		if strings.HasPrefix(k, nodeRolePrefix) {
+			add(strings.TrimPrefix(k, nodeRolePrefix))
+		}
+	}
+	add(labels["kubernetes.io/role"])
+	return roles
+}
+
 type nodeStore interface {
 	List() (v1.NodeList, error)
 }
@@ -102,17 +158,22 @@ type nodeCollector struct {
 
 // Describe implements the prometheus.Collector interface.
 func (nc *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- descNodeInfo
-	ch <- descNodeSpecUnschedulable
-	ch <- descNodeStatusReady
-	ch <- descNodeStatusOutOfDisk
-	ch <- descNodeStatusPhase
-	ch <- descNodeStatusCapacityCPU
-	ch <- descNodeStatusCapacityMemory
-	ch <- descNodeStatusCapacityPods
-	ch <- descNodeStatusAllocatableCPU
-	ch <- descNodeStatusAllocatableMemory
-	ch <- descNodeStatusAllocatablePods
+	ch <- objectDesc(descNodeInfo)
+	ch <- objectDesc(descNodeRole)
+	ch <- objectDesc(descNodeSpecUnschedulable)
+	ch <- objectDesc(descNodeSpecTaint)
+	ch <- objectDesc(descNodeStatusReady)
+	ch <- objectDesc(descNodeStatusOutOfDisk)
+	ch <- objectDesc(descNodeStatusPhase)
+	ch <- objectDesc(descNodeStatusCapacityCPU)
+	ch <- objectDesc(descNodeStatusCapacityMemory)
+	ch <- objectDesc(descNodeStatusCapacityPods)
+	ch <- objectDesc(descNodeStatusAllocatableCPU)
+	ch <- objectDesc(descNodeStatusAllocatableMemory)
+	ch <- objectDesc(descNodeStatusAllocatablePods)
+	ch <- objectDesc(descNodeReadyButUnschedulable)
+	ch <- objectDesc(descNodeImagesCount)
+	ch <- objectDesc(descNodeImagesSize)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -130,7 +191,7 @@ func (nc *nodeCollector) Collect(ch chan<- prometheus.Metric) {
 func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
 		lv = append([]string{n.Name}, lv...)
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+		ch <- mustNewObjectMetric(desc, n.UID, prometheus.GaugeValue, v, lv...)
 	}
 	// NOTE: the instrumentation API requires providing label values in order of declaration
 	// in the metric descriptor. Be careful when making modifications.
@@ -142,18 +203,34 @@ func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 		n.Status.NodeInfo.KubeProxyVersion,
 	)
 
+	for _, role := range nodeRoles(n.Labels) {
+		addGauge(descNodeRole, 1, role)
+	}
+
 	addGauge(descNodeSpecUnschedulable, boolFloat64(n.Spec.Unschedulable))
 
+	// Taints are still an alpha annotation in this API version.
+	if taints, err := v1.GetTaintsFromNodeAnnotations(n.Annotations); err != nil {
+		glog.Errorf("parsing taints of node %s failed: %s", n.Name, err)
+	} else {
+		for _, t := range taints {
+			addGauge(descNodeSpecTaint, 1, t.Key, t.Value, string(t.Effect))
+		}
+	}
+
 	// Collect node conditions and while default to false.
 	// TODO(fabxc): add remaining conditions: NodeMemoryPressure,  NodeDiskPressure, NodeNetworkUnavailable
+	ready := false
 	for _, c := range n.Status.Conditions {
 		switch c.Type {
 		case v1.NodeReady:
-			addConditionMetrics(ch, descNodeStatusReady, c.Status, n.Name)
+			ready = c.Status == v1.ConditionTrue
+			addConditionMetrics(ch, descNodeStatusReady, c.Status, n.UID, n.Name)
 		case v1.NodeOutOfDisk:
-			addConditionMetrics(ch, descNodeStatusOutOfDisk, c.Status, n.Name)
+			addConditionMetrics(ch, descNodeStatusOutOfDisk, c.Status, n.UID, n.Name)
 		}
 	}
+	addGauge(descNodeReadyButUnschedulable, boolFloat64(ready && n.Spec.Unschedulable))
 
 	// Set current phase to 1, others to 0 if it is set.
 	if p := n.Status.Phase; p != "" {
@@ -175,22 +252,31 @@ func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 	addResource(descNodeStatusAllocatableCPU, n.Status.Allocatable, v1.ResourceCPU)
 	addResource(descNodeStatusAllocatableMemory, n.Status.Allocatable, v1.ResourceMemory)
 	addResource(descNodeStatusAllocatablePods, n.Status.Allocatable, v1.ResourcePods)
+
+	// Only the images the kubelet lists in the node status are accounted.
+	var size int64
+	for _, img := range n.Status.Images {
+		size += img.SizeBytes
+	}
+	addGauge(descNodeImagesCount, float64(len(n.Status.Images)))
+	addGauge(descNodeImagesSize, float64(size))
 }
 
 // addConditionMetrics generates one metric for each possible node condition
 // status. For this function to work properly, the last label in the metric
-// description must be the condition.
-func addConditionMetrics(ch chan<- prometheus.Metric, desc *prometheus.Desc, cs v1.ConditionStatus, lv ...string) {
-	ch <- prometheus.MustNewConstMetric(
-		desc, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionTrue),
+// description must be the condition. uid is the uid of the object the
+// condition belongs to, see mustNewObjectMetric.
+func addConditionMetrics(ch chan<- prometheus.Metric, desc *prometheus.Desc, cs v1.ConditionStatus, uid types.UID, lv ...string) {
+	ch <- mustNewObjectMetric(
+		desc, uid, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionTrue),
 		append(lv, "true")...,
 	)
-	ch <- prometheus.MustNewConstMetric(
-		desc, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionFalse),
+	ch <- mustNewObjectMetric(
+		desc, uid, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionFalse),
 		append(lv, "false")...,
 	)
-	ch <- prometheus.MustNewConstMetric(
-		desc, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionUnknown),
+	ch <- mustNewObjectMetric(
+		desc, uid, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionUnknown),
 		append(lv, "unknown")...,
 	)
 }
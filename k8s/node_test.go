@@ -0,0 +1,138 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestNodeSpecTaint(t *testing.T) {
+	nodes := v1.NodeList{Items: []v1.Node{{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "tainted",
+			Annotations: map[string]string{
+				v1.TaintsAnnotationKey: `[{"key":"dedicated","value":"db","effect":"NoSchedule"}]`,
+			},
+		},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Name: "plain"},
+	}}}
+	nc := &nodeCollector{store: NodeLister(func() (v1.NodeList, error) { return nodes, nil })}
+
+	for _, mf := range gatherFrom(t, nc) {
+		if mf.GetName() != "kube_node_spec_taint" {
+			continue
+		}
+		if len(mf.GetMetric()) != 1 {
+			t.Fatalf("expected one taint series, got %d", len(mf.GetMetric()))
+		}
+		m := mf.GetMetric()[0]
+		for name, want := range map[string]string{"node": "tainted", "key": "dedicated", "value": "db", "effect": "NoSchedule"} {
+			if v := labelValue(m, name); v != want {
+				t.Errorf("expected %s %q, got %q", name, want, v)
+			}
+		}
+		return
+	}
+	t.Fatal("kube_node_spec_taint missing")
+}
+
+func TestNodeRole(t *testing.T) {
+	nodes := v1.NodeList{Items: []v1.Node{{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "cp",
+			Labels: map[string]string{
+				"node-role.kubernetes.io/control-plane": "",
+				"node-role.kubernetes.io/master":        "",
+			},
+		},
+	}, {
+		ObjectMeta: v1.ObjectMeta{
+			Name:   "worker",
+			Labels: map[string]string{"kubernetes.io/role": "worker"},
+		},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Name: "unlabeled"},
+	}}}
+	nc := &nodeCollector{store: NodeLister(func() (v1.NodeList, error) { return nodes, nil })}
+
+	roles := map[string]bool{}
+	for _, mf := range gatherFrom(t, nc) {
+		if mf.GetName() != "kube_node_role" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			roles[labelValue(m, "node")+"/"+labelValue(m, "role")] = true
+		}
+	}
+	want := map[string]bool{"cp/control-plane": true, "cp/master": true, "worker/worker": true}
+	if !reflect.DeepEqual(roles, want) {
+		t.Errorf("expected roles %v, got %v", want, roles)
+	}
+}
+
+func TestNodeImages(t *testing.T) {
+	nodes := v1.NodeList{Items: []v1.Node{{
+		ObjectMeta: v1.ObjectMeta{Name: "n1"},
+		Status: v1.NodeStatus{Images: []v1.ContainerImage{
+			{Names: []string{"busybox:1"}, SizeBytes: 1000},
+			{Names: []string{"nginx:1"}, SizeBytes: 20000},
+			{Names: []string{"redis:3"}, SizeBytes: 300000},
+		}},
+	}}}
+	nc := &nodeCollector{store: NodeLister(func() (v1.NodeList, error) { return nodes, nil })}
+
+	want := map[string]float64{
+		"kube_node_images_count":      3,
+		"kube_node_images_size_bytes": 321000,
+	}
+	for _, mf := range gatherFrom(t, nc) {
+		w, ok := want[mf.GetName()]
+		if !ok {
+			continue
+		}
+		delete(want, mf.GetName())
+		if len(mf.GetMetric()) != 1 {
+			t.Fatalf("expected one %s series, got %d", mf.GetName(), len(mf.GetMetric()))
+		}
+		if v := mf.GetMetric()[0].GetGauge().GetValue(); v != w {
+			t.Errorf("expected %s %v, got %v", mf.GetName(), w, v)
+		}
+	}
+	for name := range want {
+		t.Errorf("%s missing", name)
+	}
+}
+
+func TestNodeReadyButUnschedulable(t *testing.T) {
+	node := func(name string, unschedulable bool, ready v1.ConditionStatus) v1.Node {
+		return v1.Node{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+			Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: ready},
+			}},
+		}
+	}
+	nodes := v1.NodeList{Items: []v1.Node{
+		node("cordoned", true, v1.ConditionTrue),
+		node("broken", true, v1.ConditionFalse),
+		node("healthy", false, v1.ConditionTrue),
+	}}
+	nc := &nodeCollector{store: NodeLister(func() (v1.NodeList, error) { return nodes, nil })}
+
+	got := map[string]float64{}
+	for _, mf := range gatherFrom(t, nc) {
+		if mf.GetName() != "kube_node_ready_but_unschedulable" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			got[labelValue(m, "node")] = m.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{"cordoned": 1, "broken": 0, "healthy": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
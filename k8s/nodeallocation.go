@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var descNodeAllocatableCPUUtilization = newObjectDesc(
+	"kube_node_allocatable_cpu_utilization",
+	"The cpu requested by the pods on a node as a fraction of its allocatable cpu; above 1 the node is overcommitted.",
+	[]string{"node"},
+)
+
+// nodeAllocationCollector relates the resources requested by the pods on each
+// node to what the node can allocate, which takes both pods and nodes.
+type nodeAllocationCollector struct {
+	nodes nodeStore
+	pods  podStore
+}
+
+// Describe implements the prometheus.Collector interface.
+func (ac *nodeAllocationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descNodeAllocatableCPUUtilization)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (ac *nodeAllocationCollector) Collect(ch chan<- prometheus.Metric) {
+	nodes, err := ac.nodes.List()
+	if err != nil {
+		glog.Errorf("listing nodes failed: %s", err)
+		return
+	}
+	pods, err := ac.pods.List()
+	if err != nil {
+		glog.Errorf("listing pods failed: %s", err)
+		return
+	}
+
+	// Requested millicores per node of the pods holding resources there.
+	requested := map[string]int64{}
+	for _, p := range pods {
+		if p.Spec.NodeName == "" || p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+			continue
+		}
+		for _, c := range p.Spec.Containers {
+			if cpu, ok := c.Resources.Requests[v1.ResourceCPU]; ok {
+				requested[p.Spec.NodeName] += cpu.MilliValue()
+			}
+		}
+	}
+
+	for _, n := range nodes.Items {
+		allocatable, ok := n.Status.Allocatable[v1.ResourceCPU]
+		if !ok || allocatable.MilliValue() == 0 {
+			continue
+		}
+		ch <- mustNewObjectMetric(descNodeAllocatableCPUUtilization, n.UID, prometheus.GaugeValue,
+			float64(requested[n.Name])/float64(allocatable.MilliValue()), n.Name)
+	}
+}
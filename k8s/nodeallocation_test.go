@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/resource"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestNodeAllocatableCPUUtilization(t *testing.T) {
+	nodes := v1.NodeList{Items: []v1.Node{{
+		ObjectMeta: v1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+		},
+	}}}
+	pod := func(name, cpu string, phase v1.PodPhase) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec: v1.PodSpec{
+				NodeName: "node-1",
+				Containers: []v1.Container{{
+					Name:      "app",
+					Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+				}},
+			},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+	pods := []v1.Pod{
+		pod("web", "1500m", v1.PodRunning),
+		pod("worker", "500m", v1.PodPending),
+		pod("done", "2", v1.PodSucceeded),
+	}
+	ac := &nodeAllocationCollector{
+		nodes: NodeLister(func() (v1.NodeList, error) { return nodes, nil }),
+		pods:  PodLister(func() ([]v1.Pod, error) { return pods, nil }),
+	}
+
+	mfs := gatherFrom(t, ac)
+	if len(mfs) != 1 || len(mfs[0].GetMetric()) != 1 {
+		t.Fatalf("expected a single series, got %v", mfs)
+	}
+	if v := mfs[0].GetMetric()[0].GetGauge().GetValue(); v != 0.5 {
+		t.Errorf("expected 2 of 4 cores requested, got %v", v)
+	}
+}
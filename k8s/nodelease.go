@@ -0,0 +1,99 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// nodeLeaseNamespace holds the Lease each kubelet renews as its heartbeat,
+// named after its node.
+const nodeLeaseNamespace = "kube-node-lease"
+
+// leaseGroupVersions lists the group versions serving leases, most preferred
+// first.
+var leaseGroupVersions = []string{
+	"coordination.k8s.io/v1",
+	"coordination.k8s.io/v1beta1",
+}
+
+var descNodeLeaseRenewAge = newDesc(
+	"kube_node_lease_renew_age_seconds",
+	"Seconds since the kubelet of a node last renewed its lease.",
+	[]string{"node"},
+)
+
+// lease holds the fields of a coordination.k8s.io Lease the collector needs.
+// The vendored client-go predates the API, so leases are decoded from the raw
+// list response.
+type lease struct {
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec          struct {
+		// RenewTime is a MicroTime, RFC 3339 with microseconds.
+		RenewTime string `json:"renewTime"`
+	} `json:"spec"`
+}
+
+type leaseList struct {
+	Items []lease `json:"items"`
+}
+
+type leaseStore interface {
+	List() ([]lease, error)
+}
+
+// nodeLeaseCollector collects the age of the node leases, which kubelets
+// renew every few seconds, well before a missing heartbeat shows in the node
+// conditions.
+type nodeLeaseCollector struct {
+	store leaseStore
+	// now returns the current time; nil means time.Now.
+	now func() time.Time
+}
+
+// Describe implements the prometheus.Collector interface.
+func (lc *nodeLeaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descNodeLeaseRenewAge)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (lc *nodeLeaseCollector) Collect(ch chan<- prometheus.Metric) {
+	leases, err := lc.store.List()
+	if err != nil {
+		glog.Errorf("listing node leases failed: %s", err)
+		return
+	}
+	now := time.Now()
+	if lc.now != nil {
+		now = lc.now()
+	}
+	for _, l := range leases {
+		if l.Spec.RenewTime == "" {
+			continue
+		}
+		renewed, err := time.Parse(time.RFC3339Nano, l.Spec.RenewTime)
+		if err != nil {
+			glog.Errorf("parsing renew time of node lease %s failed: %s", l.Name, err)
+			continue
+		}
+		ch <- mustNewConstMetric(objectDesc(descNodeLeaseRenewAge), prometheus.GaugeValue, now.Sub(renewed).Seconds(), l.Name)
+	}
+}
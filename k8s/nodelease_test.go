@@ -0,0 +1,32 @@
+package k8s
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNodeLeaseCollector(t *testing.T) {
+	now := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	var l leaseList
+	err := json.Unmarshal([]byte(`{"items": [
+		{"metadata": {"namespace": "kube-node-lease", "name": "node-1"}, "spec": {"holderIdentity": "node-1", "renewTime": "2017-01-01T11:59:30.000000Z"}},
+		{"metadata": {"namespace": "kube-node-lease", "name": "node-2"}, "spec": {}}
+	]}`), &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc := &nodeLeaseCollector{
+		store: LeaseLister(func() ([]lease, error) { return l.Items, nil }),
+		now:   func() time.Time { return now },
+	}
+
+	mfs := gatherFrom(t, lc)
+	if len(mfs) != 1 || len(mfs[0].GetMetric()) != 1 {
+		t.Fatalf("expected a single lease age, got %v", mfs)
+	}
+	m := mfs[0].GetMetric()[0]
+	if labelValue(m, "node") != "node-1" || m.GetGauge().GetValue() != 30 {
+		t.Errorf("expected node-1 renewed 30s ago, got %v", m)
+	}
+}
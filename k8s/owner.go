@@ -0,0 +1,85 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// maxOwnerDepth bounds the walk up the owner chain, guarding against cycles.
+const maxOwnerDepth = 10
+
+// ownerRef identifies a controlling object, e.g. Deployment/default/web.
+type ownerRef struct {
+	kind, namespace, name string
+}
+
+// ownerTarget is the owner parsed from --owner; nil emits the metrics of
+// every pod.
+var ownerTarget *ownerRef
+
+// parseOwner parses an owner given as kind/namespace/name.
+func parseOwner(s string) (*ownerRef, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("owner %q is not of the form kind/namespace/name", s)
+	}
+	return &ownerRef{kind: parts[0], namespace: parts[1], name: parts[2]}, nil
+}
+
+// ownerLookup returns the metadata of the object of kind in namespace, if known.
+type ownerLookup func(kind, namespace, name string) (v1.ObjectMeta, bool)
+
+// ownerFilter limits emitted series to objects owned, possibly through
+// intermediate objects such as ReplicaSets, by target. A nil filter or
+// target allows every object.
+type ownerFilter struct {
+	target *ownerRef
+	lookup ownerLookup
+}
+
+func (f *ownerFilter) owns(m v1.ObjectMeta) bool {
+	if f == nil || f.target == nil {
+		return true
+	}
+	if m.Namespace != f.target.namespace {
+		return false
+	}
+	return f.ownedBy(m, 0)
+}
+
+func (f *ownerFilter) ownedBy(m v1.ObjectMeta, depth int) bool {
+	if depth >= maxOwnerDepth {
+		return false
+	}
+	for _, ref := range m.OwnerReferences {
+		if ref.Kind == f.target.kind && ref.Name == f.target.name {
+			return true
+		}
+		if f.lookup == nil {
+			continue
+		}
+		// Owner references never cross namespaces.
+		if owner, ok := f.lookup(ref.Kind, m.Namespace, ref.Name); ok && f.ownedBy(owner, depth+1) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestOwnerFilter(t *testing.T) {
+	owned := func(kind, name string) []v1.OwnerReference {
+		return []v1.OwnerReference{{Kind: kind, Name: name}}
+	}
+	replicaSets := map[string]v1.ObjectMeta{
+		"web-123":   {Namespace: "ns", Name: "web-123", OwnerReferences: owned("Deployment", "web")},
+		"other-456": {Namespace: "ns", Name: "other-456", OwnerReferences: owned("Deployment", "other")},
+	}
+	target, err := parseOwner("Deployment/ns/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := &ownerFilter{
+		target: target,
+		lookup: func(kind, namespace, name string) (v1.ObjectMeta, bool) {
+			m, ok := replicaSets[name]
+			return m, ok && kind == "ReplicaSet"
+		},
+	}
+
+	pods := []v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "web-123-a", OwnerReferences: owned("ReplicaSet", "web-123")}},
+		{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "other-456-a", OwnerReferences: owned("ReplicaSet", "other-456")}},
+		{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "bare"}},
+	}
+	info := podMetrics(t, &podCollector{owner: filter}, pods...)["kube_pod_info"]
+	if len(info) != 1 || labelValue(info[0], "pod") != "web-123-a" {
+		t.Errorf("expected only the owned pod to emit, got %v", info)
+	}
+
+	if _, err := parseOwner("Deployment/web"); err == nil {
+		t.Errorf("expected error for owner without namespace")
+	}
+}
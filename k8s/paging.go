@@ -0,0 +1,114 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/meta"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// listMeta is the part of the list metadata unknown to the vendored API
+// types that paging needs.
+type listMeta struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+}
+
+// newListWatch is cache.NewListWatchFromClient for resource in all
+// namespaces, listing in pages of --list-page-size objects, resuming from
+// the resource version persisted in --resource-version-file and retrying the
+// initial list per --initial-list-retries. newList returns an empty list of
+// the resource.
+func newListWatch(c cache.Getter, resource string, newList func() runtime.Object) *cache.ListWatch {
+	lw := cache.NewListWatchFromClient(c, resource, api.NamespaceAll, nil)
+	lw = pagedListWatch(lw, c, resource, api.NamespaceAll, newList, *listPageSize)
+	lw = resumeListWatch(resource, lw, persistedVersions)
+	return retryInitialList(resource, lw, *initialListRetries, *initialListBackoff)
+}
+
+// pagedListWatch returns lw listing resource in pages of limit objects,
+// following the continue tokens of the apiserver, so that no single response
+// holds all objects of a large cluster. Servers not supporting paging
+// ignore the limit and return everything at once. A limit of 0 returns lw.
+//
+// The reflector lists at resource version 0 to be served from the watch
+// cache, which ignores the limit, so paging turns every list and relist
+// into a read from etcd. That is why paging is off unless configured.
+func pagedListWatch(lw *cache.ListWatch, c cache.Getter, resource, namespace string, newList func() runtime.Object, limit int64) *cache.ListWatch {
+	if limit <= 0 {
+		return lw
+	}
+	return &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			// See above: the limit only applies to lists not served
+			// from the watch cache.
+			if options.ResourceVersion == "0" {
+				options.ResourceVersion = ""
+			}
+
+			var (
+				list  runtime.Object
+				items []runtime.Object
+				token string
+			)
+			for {
+				req := c.Get().
+					Namespace(namespace).
+					Resource(resource).
+					VersionedParams(&options, api.ParameterCodec).
+					Param("limit", strconv.FormatInt(limit, 10))
+				if token != "" {
+					req = req.Param("continue", token)
+				}
+				bs, err := req.DoRaw()
+				if err != nil {
+					return nil, err
+				}
+				page := newList()
+				if err := json.Unmarshal(bs, page); err != nil {
+					return nil, err
+				}
+				var m listMeta
+				if err := json.Unmarshal(bs, &m); err != nil {
+					return nil, err
+				}
+				pageItems, err := meta.ExtractList(page)
+				if err != nil {
+					return nil, err
+				}
+				// All pages share the resource version of the first.
+				list, items = page, append(items, pageItems...)
+				if token = m.Metadata.Continue; token == "" {
+					break
+				}
+			}
+			if err := meta.SetList(list, items); err != nil {
+				return nil, err
+			}
+			api.Scheme.Default(list)
+			return list, nil
+		},
+		WatchFunc: lw.WatchFunc,
+	}
+}
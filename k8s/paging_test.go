@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPagedListWatch(t *testing.T) {
+	var queries []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, map[string]string{"limit": q.Get("limit"), "continue": q.Get("continue"), "resourceVersion": q.Get("resourceVersion")})
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("continue") == "" {
+			w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10","continue":"next"},"items":[{"metadata":{"name":"a"}},{"metadata":{"name":"b"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"c"}}]}`))
+	}))
+	defer srv.Close()
+
+	client, err := clientset.NewForConfig(&restclient.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.Core().RESTClient()
+	lw := pagedListWatch(cache.NewListWatchFromClient(c, "pods", api.NamespaceAll, nil), c, "pods", api.NamespaceAll, func() runtime.Object { return &v1.PodList{} }, 2)
+
+	obj, err := lw.List(v1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := obj.(*v1.PodList)
+	if len(pods.Items) != 3 || pods.Items[2].Name != "c" || pods.ResourceVersion != "10" {
+		t.Errorf("expected the pods of both pages at resource version 10, got %+v", pods)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected two pages to be requested, got %v", queries)
+	}
+	for i, want := range []string{"", "next"} {
+		q := queries[i]
+		if q["limit"] != "2" || q["continue"] != want || q["resourceVersion"] != "" {
+			t.Errorf("page %d: expected limit 2, continue %q and no resource version, got %v", i, want, q)
+		}
+	}
+}
@@ -17,113 +17,231 @@ limitations under the License.
 package k8s
 
 import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/pkg/api/v1"
 )
 
 var (
-	descPodInfo = prometheus.NewDesc(
+	descPodInfo = newObjectDesc(
 		"kube_pod_info",
 		"Information about pod.",
-		[]string{"namespace", "pod", "host_ip", "pod_ip"}, nil,
+		[]string{"namespace", "pod", "host_ip", "pod_ip", "node"},
+	)
+	descPodLabelsHash = newObjectDesc(
+		"kube_pod_labels_hash",
+		"A hash of the pod labels selected by --label-hash-keys, to detect label changes without a label per key.",
+		[]string{"namespace", "pod", "labels_hash"},
+	)
+	descPodContainerCount = newObjectDesc(
+		"kube_pod_container_count",
+		"The number of containers in the spec of a pod.",
+		[]string{"namespace", "pod"},
+	)
+	descPodInitContainerCount = newObjectDesc(
+		"kube_pod_init_container_count",
+		"The number of init containers in the spec of a pod.",
+		[]string{"namespace", "pod"},
+	)
+	descPodSpecTerminationGracePeriod = newObjectDesc(
+		"kube_pod_spec_termination_grace_period_seconds",
+		"The seconds a pod is given to terminate gracefully when deleted.",
+		[]string{"namespace", "pod"},
+	)
+	descPodDeletionInProgress = newObjectDesc(
+		"kube_pod_deletion_in_progress",
+		"Describes whether a pod is being deleted, i.e. has a deletion timestamp.",
+		[]string{"namespace", "pod"},
 	)
-	descPodStatusPhase = prometheus.NewDesc(
+	descPodRestartThresholdExceeded = newObjectDesc(
+		"kube_pod_restart_threshold_exceeded",
+		"Describes whether a container of the pod restarted at least --pod-restart-threshold times.",
+		[]string{"namespace", "pod"},
+	)
+	descPodSpecNodeSelector = newObjectDesc(
+		"kube_pod_spec_node_selector",
+		"The node selector terms of a pod, limited to allowlisted keys.",
+		[]string{"namespace", "pod", "key", "value"},
+	)
+	descPodSpecTolerations = newObjectDesc(
+		"kube_pod_spec_tolerations",
+		"The number of taints tolerated by a pod.",
+		[]string{"namespace", "pod"},
+	)
+	descPodStatusPhase = newObjectDesc(
 		"kube_pod_status_phase",
 		"The pods current phase.",
-		[]string{"namespace", "pod", "phase"}, nil,
+		[]string{"namespace", "pod", "phase"},
 	)
-	descPodStatusReady = prometheus.NewDesc(
+	descPodStatusReady = newObjectDesc(
 		"kube_pod_status_ready",
 		"Describes whether the pod is ready to serve requests.",
-		[]string{"namespace", "pod", "condition"}, nil,
+		[]string{"namespace", "pod", "condition"},
 	)
-	descPodStatusScheduled = prometheus.NewDesc(
+	descPodStatusScheduled = newObjectDesc(
 		"kube_pod_status_scheduled",
 		"Describes the status of the scheduling process for the pod.",
-		[]string{"namespace", "pod", "condition"}, nil,
+		[]string{"namespace", "pod", "condition"},
 	)
-	descPodContainerInfo = prometheus.NewDesc(
+	descPodUnschedulable = newObjectDesc(
+		"kube_pod_unschedulable",
+		"Describes pods the scheduler failed to place, with its message why, truncated.",
+		[]string{"namespace", "pod", "message"},
+	)
+	descPodContainerInfo = newObjectDesc(
 		"kube_pod_container_info",
 		"Information about a container in a pod.",
-		[]string{"namespace", "pod", "container", "image", "image_id", "container_id"}, nil,
+		[]string{"namespace", "pod", "container", "image", "image_id", "container_id"},
 	)
-	descPodContainerStatusWaiting = prometheus.NewDesc(
+	descPodContainerStatusWaiting = newObjectDesc(
 		"kube_pod_container_status_waiting",
 		"Describes whether the container is currently in waiting state.",
-		[]string{"namespace", "pod", "container"}, nil,
+		[]string{"namespace", "pod", "container"},
 	)
-	descPodContainerStatusRunning = prometheus.NewDesc(
+	descPodContainerStatusRunning = newObjectDesc(
 		"kube_pod_container_status_running",
 		"Describes whether the container is currently in running state.",
-		[]string{"namespace", "pod", "container"}, nil,
+		[]string{"namespace", "pod", "container"},
 	)
-	descPodContainerStatusTerminated = prometheus.NewDesc(
+	descPodContainerStatusTerminated = newObjectDesc(
 		"kube_pod_container_status_terminated",
 		"Describes whether the container is currently in terminated state.",
-		[]string{"namespace", "pod", "container"}, nil,
+		[]string{"namespace", "pod", "container"},
+	)
+	descPodContainerStatus = newObjectDesc(
+		"kube_pod_container_status",
+		"Describes the state a container is in and, if waiting or terminated, why.",
+		[]string{"namespace", "pod", "container", "state", "reason"},
+	)
+	descPodContainerStatusLastTerminatedOOMKilled = newObjectDesc(
+		"kube_pod_container_status_last_terminated_oomkilled",
+		"Describes whether the container is or was last terminated for running out of memory.",
+		[]string{"namespace", "pod", "container"},
 	)
-	descPodContainerStatusReady = prometheus.NewDesc(
+	descPodContainerStartLatency = newObjectDesc(
+		"kube_pod_container_start_latency_seconds",
+		"Time from the pod being scheduled until the running container started, including image pulls. Not reported for restarted containers.",
+		[]string{"namespace", "pod", "container"},
+	)
+	descPodContainerStatusReady = newObjectDesc(
 		"kube_pod_container_status_ready",
 		"Describes whether the containers readiness check succeeded.",
-		[]string{"namespace", "pod", "container"}, nil,
+		[]string{"namespace", "pod", "container"},
 	)
-	descPodContainerStatusRestarts = prometheus.NewDesc(
+	descPodContainerStatusRestarts = newObjectDesc(
 		"kube_pod_container_status_restarts",
 		"The number of container restarts per container.",
-		[]string{"namespace", "pod", "container"}, nil,
+		[]string{"namespace", "pod", "container"},
 	)
 
-	descPodContainerRequestedCpuCores = prometheus.NewDesc(
+	descPodContainerRequestedCpuCores = newObjectDesc(
 		"kube_pod_container_requested_cpu_cores",
 		"The number of requested cpu cores by a container.",
-		[]string{"namespace", "pod", "container", "node"}, nil,
+		[]string{"namespace", "pod", "container", "node"},
 	)
 
-	descPodContainerRequestedMemoryBytes = prometheus.NewDesc(
+	descPodContainerRequestedMemoryBytes = newObjectDesc(
 		"kube_pod_container_requested_memory_bytes",
 		"The number of requested memory bytes  by a container.",
-		[]string{"namespace", "pod", "container", "node"}, nil,
+		[]string{"namespace", "pod", "container", "node"},
 	)
 
-	descPodContainerLimitsCpuCores = prometheus.NewDesc(
+	descPodContainerLimitsCpuCores = newObjectDesc(
 		"kube_pod_container_limits_cpu_cores",
 		"The limit on cpu cores to be used by a container.",
-		[]string{"namespace", "pod", "container", "node"}, nil,
+		[]string{"namespace", "pod", "container", "node"},
 	)
 
-	descPodContainerLimitsMemoryBytes = prometheus.NewDesc(
+	descPodContainerLimitsMemoryBytes = newObjectDesc(
 		"kube_pod_container_limits_memory_bytes",
 		"The limit on memory to be used by a container in bytes.",
-		[]string{"namespace", "pod", "container", "node"}, nil,
+		[]string{"namespace", "pod", "container", "node"},
 	)
 )
 
+// initContainerCount returns the number of init containers of p. They are
+// still an annotation in this API version and not decoded into the spec.
+func initContainerCount(p v1.Pod) int {
+	if len(p.Spec.InitContainers) > 0 {
+		return len(p.Spec.InitContainers)
+	}
+	for _, key := range []string{v1.PodInitContainersBetaAnnotationKey, v1.PodInitContainersAnnotationKey} {
+		if value, ok := p.Annotations[key]; ok {
+			var containers []json.RawMessage
+			if err := json.Unmarshal([]byte(value), &containers); err != nil {
+				glog.Errorf("parsing init containers of pod %s/%s failed: %s", p.Namespace, p.Name, err)
+				return 0
+			}
+			return len(containers)
+		}
+	}
+	return 0
+}
+
+// maxUnschedulableMessageLen bounds the message label of
+// kube_pod_unschedulable, whose messages can list a reason per node.
+const maxUnschedulableMessageLen = 128
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
 type podStore interface {
 	List() (pods []v1.Pod, err error)
 }
 
 // podCollector collects metrics about all pods in the cluster.
 type podCollector struct {
-	store podStore
+	store      podStore
+	namespaces namespaceFilter
+	owner      *ownerFilter
+	// nodeSelectorKeys limits the node selector keys exported, to keep
+	// cardinality in check. Nothing is exported when it is empty.
+	nodeSelectorKeys map[string]bool
+	// labelHashKeys are the pod labels hashed into kube_pod_labels_hash,
+	// sorted. Nothing is exported when it is empty.
+	labelHashKeys []string
+	// restartThreshold is the restart count from which a pod is reported
+	// in kube_pod_restart_threshold_exceeded; 0 disables the metric.
+	restartThreshold int32
 }
 
 // Describe implements the prometheus.Collector interface.
 func (pc *podCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- descPodInfo
-	ch <- descPodStatusPhase
-	ch <- descPodStatusReady
-	ch <- descPodStatusScheduled
-	ch <- descPodContainerInfo
-	ch <- descPodContainerStatusWaiting
-	ch <- descPodContainerStatusRunning
-	ch <- descPodContainerStatusTerminated
-	ch <- descPodContainerStatusReady
-	ch <- descPodContainerStatusRestarts
-	ch <- descPodContainerRequestedCpuCores
-	ch <- descPodContainerRequestedMemoryBytes
-	ch <- descPodContainerLimitsCpuCores
-	ch <- descPodContainerLimitsMemoryBytes
+	ch <- objectDesc(descPodInfo)
+	ch <- objectDesc(descPodLabelsHash)
+	ch <- objectDesc(descPodContainerCount)
+	ch <- objectDesc(descPodInitContainerCount)
+	ch <- objectDesc(descPodSpecTerminationGracePeriod)
+	ch <- objectDesc(descPodDeletionInProgress)
+	ch <- objectDesc(descPodSpecNodeSelector)
+	ch <- objectDesc(descPodSpecTolerations)
+	ch <- objectDesc(descPodStatusPhase)
+	ch <- objectDesc(descPodStatusReady)
+	ch <- objectDesc(descPodStatusScheduled)
+	ch <- objectDesc(descPodUnschedulable)
+	ch <- objectDesc(descPodContainerInfo)
+	ch <- objectDesc(descPodContainerStatusWaiting)
+	ch <- objectDesc(descPodContainerStatusRunning)
+	ch <- objectDesc(descPodContainerStatusTerminated)
+	ch <- objectDesc(descPodContainerStatus)
+	ch <- objectDesc(descPodContainerStatusLastTerminatedOOMKilled)
+	ch <- objectDesc(descPodContainerStartLatency)
+	ch <- objectDesc(descPodContainerStatusReady)
+	ch <- objectDesc(descPodContainerStatusRestarts)
+	ch <- objectDesc(descPodRestartThresholdExceeded)
+	ch <- objectDesc(descPodContainerRequestedCpuCores)
+	ch <- objectDesc(descPodContainerRequestedMemoryBytes)
+	ch <- objectDesc(descPodContainerLimitsCpuCores)
+	ch <- objectDesc(descPodContainerLimitsMemoryBytes)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -134,6 +252,9 @@ func (pc *podCollector) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 	for _, p := range pods {
+		if !pc.namespaces.allowed(p.Namespace) || !pc.owner.owns(p.ObjectMeta) {
+			continue
+		}
 		pc.collectPod(ch, p)
 	}
 }
@@ -141,7 +262,7 @@ func (pc *podCollector) Collect(ch chan<- prometheus.Metric) {
 func (pc *podCollector) collectPod(ch chan<- prometheus.Metric, p v1.Pod) {
 	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
 		lv = append([]string{p.Namespace, p.Name}, lv...)
-		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
+		ch <- mustNewObjectMetric(desc, p.UID, t, v, lv...)
 	}
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
 		addConstMetric(desc, prometheus.GaugeValue, v, lv...)
@@ -150,28 +271,73 @@ func (pc *podCollector) collectPod(ch chan<- prometheus.Metric, p v1.Pod) {
 		addConstMetric(desc, prometheus.CounterValue, v, lv...)
 	}
 
-	addGauge(descPodInfo, 1, p.Status.HostIP, p.Status.PodIP)
+	// Pending pods have no IPs or node yet and report them empty.
+	addGauge(descPodInfo, 1, p.Status.HostIP, p.Status.PodIP, p.Spec.NodeName)
 	addGauge(descPodStatusPhase, 1, string(p.Status.Phase))
+	if len(pc.labelHashKeys) > 0 {
+		addGauge(descPodLabelsHash, 1, labelsHash(p.Labels, pc.labelHashKeys))
+	}
 
-	for _, c := range p.Status.Conditions {
+	addGauge(descPodContainerCount, float64(len(p.Spec.Containers)))
+	addGauge(descPodInitContainerCount, float64(initContainerCount(p)))
+
+	if p.Spec.TerminationGracePeriodSeconds != nil {
+		addGauge(descPodSpecTerminationGracePeriod, float64(*p.Spec.TerminationGracePeriodSeconds))
+	}
+	addGauge(descPodDeletionInProgress, boolFloat64(p.DeletionTimestamp != nil))
+
+	for k, v := range p.Spec.NodeSelector {
+		if pc.nodeSelectorKeys[k] {
+			addGauge(descPodSpecNodeSelector, 1, k, v)
+		}
+	}
+	// Tolerations are still an alpha annotation in this API version.
+	if tolerations, err := v1.GetTolerationsFromPodAnnotations(p.Annotations); err != nil {
+		glog.Errorf("parsing tolerations of pod %s/%s failed: %s", p.Namespace, p.Name, err)
+	} else {
+		addGauge(descPodSpecTolerations, float64(len(tolerations)))
+	}
+
+	var scheduled *v1.PodCondition
+	for i, c := range p.Status.Conditions {
 		switch c.Type {
 		case v1.PodReady:
-			addConditionMetrics(ch, descPodStatusReady, c.Status, p.Namespace, p.Name)
+			addConditionMetrics(ch, descPodStatusReady, c.Status, p.UID, p.Namespace, p.Name)
 		case v1.PodScheduled:
-			addConditionMetrics(ch, descPodStatusScheduled, c.Status, p.Namespace, p.Name)
+			addConditionMetrics(ch, descPodStatusScheduled, c.Status, p.UID, p.Namespace, p.Name)
+			if c.Status == v1.ConditionTrue {
+				scheduled = &p.Status.Conditions[i]
+			}
+			if c.Status == v1.ConditionFalse && c.Reason == v1.PodReasonUnschedulable {
+				addGauge(descPodUnschedulable, 1, truncate(c.Message, maxUnschedulableMessageLen))
+			}
 		}
 	}
 
+	var maxRestarts int32
 	for _, cs := range p.Status.ContainerStatuses {
+		if cs.RestartCount > maxRestarts {
+			maxRestarts = cs.RestartCount
+		}
 		addGauge(descPodContainerInfo, 1,
 			cs.Name, cs.Image, cs.ImageID, cs.ContainerID,
 		)
 		addGauge(descPodContainerStatusWaiting, boolFloat64(cs.State.Waiting != nil), cs.Name)
 		addGauge(descPodContainerStatusRunning, boolFloat64(cs.State.Running != nil), cs.Name)
 		addGauge(descPodContainerStatusTerminated, boolFloat64(cs.State.Terminated != nil), cs.Name)
+		addContainerStateMetrics(addGauge, cs)
+		addGauge(descPodContainerStatusLastTerminatedOOMKilled, boolFloat64(oomKilled(cs)), cs.Name)
+		// The start time of a restarted container is that of its last
+		// restart, not of the start after scheduling.
+		if r := cs.State.Running; r != nil && cs.RestartCount == 0 && scheduled != nil && !r.StartedAt.IsZero() && !scheduled.LastTransitionTime.IsZero() {
+			addGauge(descPodContainerStartLatency, r.StartedAt.Sub(scheduled.LastTransitionTime.Time).Seconds(), cs.Name)
+		}
 		addGauge(descPodContainerStatusReady, boolFloat64(cs.Ready), cs.Name)
 		addCounter(descPodContainerStatusRestarts, float64(cs.RestartCount), cs.Name)
 	}
+	if pc.restartThreshold > 0 {
+		addGauge(descPodRestartThresholdExceeded, boolFloat64(maxRestarts >= pc.restartThreshold))
+	}
 
 	nodeName := p.Spec.NodeName
 	for _, c := range p.Spec.Containers {
@@ -197,3 +363,53 @@ func (pc *podCollector) collectPod(ch chan<- prometheus.Metric, p v1.Pod) {
 		}
 	}
 }
+
+// addContainerStateMetrics emits one series per container state, set to 1 for
+// the current state. The current state carries its reason, e.g.
+// CrashLoopBackOff or ImagePullBackOff for waiting containers.
+func addContainerStateMetrics(addGauge func(*prometheus.Desc, float64, ...string), cs v1.ContainerStatus) {
+	state, reason := "", ""
+	switch {
+	case cs.State.Waiting != nil:
+		state, reason = "waiting", cs.State.Waiting.Reason
+	case cs.State.Running != nil:
+		state = "running"
+	case cs.State.Terminated != nil:
+		state, reason = "terminated", cs.State.Terminated.Reason
+	}
+	for _, s := range []string{"waiting", "running", "terminated"} {
+		if s == state {
+			addGauge(descPodContainerStatus, 1, cs.Name, s, reason)
+		} else {
+			addGauge(descPodContainerStatus, 0, cs.Name, s, "")
+		}
+	}
+}
+
+// oomKilled reports whether the container is terminated or was last
+// terminated for running out of memory.
+func oomKilled(cs v1.ContainerStatus) bool {
+	for _, t := range []*v1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+		if t != nil {
+			return t.Reason == "OOMKilled"
+		}
+	}
+	return false
+}
+
+// labelsHash returns a hex FNV-1a hash of the values of keys in labels. keys
+// must be sorted; absent keys hash differently from empty values.
+func labelsHash(labels map[string]string, keys []string) string {
+	h := fnv.New64a()
+	for _, k := range keys {
+		v, ok := labels[k]
+		if !ok {
+			continue
+		}
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,314 @@
+package k8s
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// podMetrics gathers the pod collector's metrics for pods, keyed by name.
+func podMetrics(t *testing.T, pc *podCollector, pods ...v1.Pod) map[string][]*dto.Metric {
+	pc.store = PodLister(func() ([]v1.Pod, error) { return pods, nil })
+	res := map[string][]*dto.Metric{}
+	for _, mf := range gatherFrom(t, pc) {
+		res[mf.GetName()] = mf.GetMetric()
+	}
+	return res
+}
+
+func TestPodContainerStatus(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name: "c",
+				State: v1.ContainerState{
+					Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+				},
+			}},
+		},
+	}
+
+	ms := podMetrics(t, &podCollector{}, pod)["kube_pod_container_status"]
+	if len(ms) != 3 {
+		t.Fatalf("expected one series per state, got %d", len(ms))
+	}
+	for _, m := range ms {
+		state, reason, v := labelValue(m, "state"), labelValue(m, "reason"), m.GetGauge().GetValue()
+		switch state {
+		case "waiting":
+			if v != 1 || reason != "CrashLoopBackOff" {
+				t.Errorf("waiting: expected 1 with reason CrashLoopBackOff, got %v %q", v, reason)
+			}
+		default:
+			if v != 0 || reason != "" {
+				t.Errorf("%s: expected 0 without reason, got %v %q", state, v, reason)
+			}
+		}
+	}
+}
+
+func TestPodSpecSchedulingMetrics(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "p",
+			Annotations: map[string]string{
+				v1.TolerationsAnnotationKey: `[{"key":"dedicated","operator":"Equal","value":"db","effect":"NoSchedule"},{"key":"gpu","operator":"Exists"}]`,
+			},
+		},
+		Spec: v1.PodSpec{
+			NodeSelector: map[string]string{"disktype": "ssd", "zone": "a"},
+		},
+	}
+
+	ms := podMetrics(t, &podCollector{nodeSelectorKeys: stringSet([]string{"disktype"})}, pod)
+
+	sel := ms["kube_pod_spec_node_selector"]
+	if len(sel) != 1 || labelValue(sel[0], "key") != "disktype" || labelValue(sel[0], "value") != "ssd" {
+		t.Errorf("expected only the allowlisted selector, got %v", sel)
+	}
+	if tol := ms["kube_pod_spec_tolerations"]; len(tol) != 1 || tol[0].GetGauge().GetValue() != 2 {
+		t.Errorf("expected two tolerations, got %v", tol)
+	}
+}
+
+func TestPodInfo(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, HostIP: "10.0.0.1", PodIP: "172.16.0.5"},
+	}
+
+	info := podMetrics(t, &podCollector{}, pod)["kube_pod_info"]
+	if len(info) != 1 {
+		t.Fatalf("expected one kube_pod_info series, got %d", len(info))
+	}
+	for label, want := range map[string]string{"host_ip": "10.0.0.1", "pod_ip": "172.16.0.5", "node": "node-1"} {
+		if v := labelValue(info[0], label); v != want {
+			t.Errorf("%s: expected %q, got %q", label, want, v)
+		}
+	}
+}
+
+func TestPodContainerCount(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "migrate"}},
+			Containers:     []v1.Container{{Name: "app"}, {Name: "proxy"}, {Name: "logs"}},
+		},
+	}
+
+	ms := podMetrics(t, &podCollector{}, pod)
+	for name, want := range map[string]float64{"kube_pod_container_count": 3, "kube_pod_init_container_count": 1} {
+		if len(ms[name]) != 1 {
+			t.Fatalf("%s: expected one series, got %d", name, len(ms[name]))
+		}
+		if v := ms[name][0].GetGauge().GetValue(); v != want {
+			t.Errorf("%s: expected %v, got %v", name, want, v)
+		}
+	}
+
+	// Pods decoded from the API carry their init containers as annotation.
+	pod.Spec.InitContainers = nil
+	pod.Annotations = map[string]string{v1.PodInitContainersBetaAnnotationKey: `[{"name":"migrate"},{"name":"seed"}]`}
+	ms = podMetrics(t, &podCollector{}, pod)
+	if v := ms["kube_pod_init_container_count"][0].GetGauge().GetValue(); v != 2 {
+		t.Errorf("expected 2 init containers from the annotation, got %v", v)
+	}
+}
+
+func TestPodTermination(t *testing.T) {
+	grace := int64(30)
+	deleted := unversioned.NewTime(time.Now())
+	pods := []v1.Pod{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "terminating", DeletionTimestamp: &deleted},
+		Spec:       v1.PodSpec{TerminationGracePeriodSeconds: &grace},
+	}, {
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "running"},
+	}}
+
+	ms := podMetrics(t, &podCollector{}, pods...)
+	if grace := ms["kube_pod_spec_termination_grace_period_seconds"]; len(grace) != 1 || grace[0].GetGauge().GetValue() != 30 {
+		t.Errorf("expected a 30s grace period for the terminating pod only, got %v", grace)
+	}
+	for _, m := range ms["kube_pod_deletion_in_progress"] {
+		want := float64(0)
+		if labelValue(m, "pod") == "terminating" {
+			want = 1
+		}
+		if v := m.GetGauge().GetValue(); v != want {
+			t.Errorf("%s: expected deletion in progress %v, got %v", labelValue(m, "pod"), want, v)
+		}
+	}
+	if n := len(ms["kube_pod_deletion_in_progress"]); n != 2 {
+		t.Errorf("expected a deletion series per pod, got %d", n)
+	}
+}
+
+func TestPodUnschedulable(t *testing.T) {
+	message := "0/3 nodes are available: 3 Insufficient cpu." + strings.Repeat(" padding", 50)
+	unschedulable := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pending"},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{{
+				Type:    v1.PodScheduled,
+				Status:  v1.ConditionFalse,
+				Reason:  v1.PodReasonUnschedulable,
+				Message: message,
+			}},
+		},
+	}
+	scheduled := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "running"},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionTrue}},
+		},
+	}
+
+	ms := podMetrics(t, &podCollector{}, unschedulable, scheduled)["kube_pod_unschedulable"]
+	if len(ms) != 1 {
+		t.Fatalf("expected a series for the unschedulable pod only, got %d", len(ms))
+	}
+	if p := labelValue(ms[0], "pod"); p != "pending" {
+		t.Errorf("expected the series of pod pending, got %s", p)
+	}
+	if m := labelValue(ms[0], "message"); m != message[:maxUnschedulableMessageLen] {
+		t.Errorf("expected the truncated message, got %q", m)
+	}
+	if v := ms[0].GetGauge().GetValue(); v != 1 {
+		t.Errorf("expected 1, got %v", v)
+	}
+}
+
+func TestPodContainerStartLatency(t *testing.T) {
+	scheduled := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{{
+				Type:               v1.PodScheduled,
+				Status:             v1.ConditionTrue,
+				LastTransitionTime: unversioned.NewTime(scheduled),
+			}},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name: "started",
+				State: v1.ContainerState{
+					Running: &v1.ContainerStateRunning{StartedAt: unversioned.NewTime(scheduled.Add(12 * time.Second))},
+				},
+			}, {
+				Name: "restarted",
+				State: v1.ContainerState{
+					Running: &v1.ContainerStateRunning{StartedAt: unversioned.NewTime(scheduled.Add(time.Hour))},
+				},
+				RestartCount: 3,
+			}, {
+				Name: "pulling",
+				State: v1.ContainerState{
+					Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"},
+				},
+			}},
+		},
+	}
+
+	ms := podMetrics(t, &podCollector{}, pod)["kube_pod_container_start_latency_seconds"]
+	if len(ms) != 1 {
+		t.Fatalf("expected latency of the running container started once only, got %d series", len(ms))
+	}
+	if c, v := labelValue(ms[0], "container"), ms[0].GetGauge().GetValue(); c != "started" || v != 12 {
+		t.Errorf("expected 12s for container started, got %vs for %s", v, c)
+	}
+}
+
+func TestPodContainerLastTerminatedOOMKilled(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "p"},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:                 "oom",
+				State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled"}},
+			}, {
+				Name:                 "error",
+				State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error"}},
+			}, {
+				Name:  "fresh",
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+			}},
+		},
+	}
+
+	ms := podMetrics(t, &podCollector{}, pod)["kube_pod_container_status_last_terminated_oomkilled"]
+	if len(ms) != 3 {
+		t.Fatalf("expected one series per container, got %d", len(ms))
+	}
+	for _, m := range ms {
+		want := 0.0
+		if labelValue(m, "container") == "oom" {
+			want = 1
+		}
+		if v := m.GetGauge().GetValue(); v != want {
+			t.Errorf("%s: expected %v, got %v", labelValue(m, "container"), want, v)
+		}
+	}
+}
+
+func TestPodLabelsHash(t *testing.T) {
+	pod := func(name string, labels map[string]string) v1.Pod {
+		return v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: name, Labels: labels}}
+	}
+	pc := &podCollector{labelHashKeys: []string{"app", "version"}}
+	ms := podMetrics(t, pc,
+		pod("a", map[string]string{"app": "web", "version": "1", "pod-template-hash": "x"}),
+		pod("b", map[string]string{"app": "web", "version": "1", "pod-template-hash": "y"}),
+		pod("c", map[string]string{"app": "web", "version": "2"}),
+	)["kube_pod_labels_hash"]
+
+	hashes := map[string]string{}
+	for _, m := range ms {
+		hashes[labelValue(m, "pod")] = labelValue(m, "labels_hash")
+	}
+	if len(hashes) != 3 || hashes["a"] == "" {
+		t.Fatalf("expected a hash per pod, got %v", hashes)
+	}
+	if hashes["a"] != hashes["b"] {
+		t.Errorf("expected identical selected labels to hash identically, got %v", hashes)
+	}
+	if hashes["a"] == hashes["c"] {
+		t.Errorf("expected different selected labels to hash differently, got %v", hashes)
+	}
+}
+
+func TestPodRestartThreshold(t *testing.T) {
+	pod := func(name string, restarts ...int32) v1.Pod {
+		p := v1.Pod{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: name}}
+		for i, r := range restarts {
+			p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, v1.ContainerStatus{Name: "c" + strconv.Itoa(i), RestartCount: r})
+		}
+		return p
+	}
+	pods := []v1.Pod{pod("flapping", 0, 10), pod("at-threshold", 5), pod("stable", 4, 1), pod("pending")}
+
+	ms := podMetrics(t, &podCollector{restartThreshold: 5}, pods...)
+	exceeded := map[string]float64{}
+	for _, m := range ms["kube_pod_restart_threshold_exceeded"] {
+		exceeded[labelValue(m, "pod")] = m.GetGauge().GetValue()
+	}
+	want := map[string]float64{"flapping": 1, "at-threshold": 1, "stable": 0, "pending": 0}
+	if !reflect.DeepEqual(exceeded, want) {
+		t.Errorf("expected %v, got %v", want, exceeded)
+	}
+
+	ms = podMetrics(t, &podCollector{}, pods...)
+	if len(ms["kube_pod_restart_threshold_exceeded"]) != 0 {
+		t.Error("expected no restart threshold metric unless a threshold is set")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
+)
+
+var (
+	descPodDisruptionBudgetStatusCurrentHealthy = newObjectDesc(
+		"kube_poddisruptionbudget_status_current_healthy",
+		"Current number of healthy pods.",
+		[]string{"namespace", "poddisruptionbudget"},
+	)
+	descPodDisruptionBudgetStatusDesiredHealthy = newObjectDesc(
+		"kube_poddisruptionbudget_status_desired_healthy",
+		"Minimum desired number of healthy pods.",
+		[]string{"namespace", "poddisruptionbudget"},
+	)
+	descPodDisruptionBudgetStatusPodDisruptionsAllowed = newObjectDesc(
+		"kube_poddisruptionbudget_status_pod_disruptions_allowed",
+		"Number of pod disruptions that are currently allowed.",
+		[]string{"namespace", "poddisruptionbudget"},
+	)
+	descPodDisruptionBudgetStatusExpectedPods = newObjectDesc(
+		"kube_poddisruptionbudget_status_expected_pods",
+		"Total number of pods counted by this disruption budget.",
+		[]string{"namespace", "poddisruptionbudget"},
+	)
+)
+
+// pdbGroupVersions lists the group versions serving pod disruption budgets.
+var pdbGroupVersions = []string{"policy/v1beta1"}
+
+type pdbStore interface {
+	List() ([]policy.PodDisruptionBudget, error)
+}
+
+// pdbCollector collects metrics about all pod disruption budgets in the
+// cluster.
+type pdbCollector struct {
+	store      pdbStore
+	namespaces namespaceFilter
+}
+
+// Describe implements the prometheus.Collector interface.
+func (pc *pdbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descPodDisruptionBudgetStatusCurrentHealthy)
+	ch <- objectDesc(descPodDisruptionBudgetStatusDesiredHealthy)
+	ch <- objectDesc(descPodDisruptionBudgetStatusPodDisruptionsAllowed)
+	ch <- objectDesc(descPodDisruptionBudgetStatusExpectedPods)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (pc *pdbCollector) Collect(ch chan<- prometheus.Metric) {
+	pdbs, err := pc.store.List()
+	if err != nil {
+		glog.Errorf("listing pod disruption budgets failed: %s", err)
+		return
+	}
+	for _, p := range pdbs {
+		if !pc.namespaces.allowed(p.Namespace) {
+			continue
+		}
+		pc.collectPDB(ch, p)
+	}
+}
+
+func (pc *pdbCollector) collectPDB(ch chan<- prometheus.Metric, p policy.PodDisruptionBudget) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		lv = append([]string{p.Namespace, p.Name}, lv...)
+		ch <- mustNewObjectMetric(desc, p.UID, prometheus.GaugeValue, v, lv...)
+	}
+	addGauge(descPodDisruptionBudgetStatusCurrentHealthy, float64(p.Status.CurrentHealthy))
+	addGauge(descPodDisruptionBudgetStatusDesiredHealthy, float64(p.Status.DesiredHealthy))
+	addGauge(descPodDisruptionBudgetStatusPodDisruptionsAllowed, float64(p.Status.PodDisruptionsAllowed))
+	addGauge(descPodDisruptionBudgetStatusExpectedPods, float64(p.Status.ExpectedPods))
+}
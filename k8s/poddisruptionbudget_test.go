@@ -0,0 +1,38 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
+)
+
+func TestPDBCollector(t *testing.T) {
+	pdbs := []policy.PodDisruptionBudget{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "web"},
+		Status: policy.PodDisruptionBudgetStatus{
+			PodDisruptionsAllowed: 1,
+			CurrentHealthy:        3,
+			DesiredHealthy:        2,
+			ExpectedPods:          3,
+		},
+	}}
+	pc := &pdbCollector{store: PDBLister(func() ([]policy.PodDisruptionBudget, error) { return pdbs, nil })}
+
+	want := map[string]float64{
+		"kube_poddisruptionbudget_status_pod_disruptions_allowed": 1,
+		"kube_poddisruptionbudget_status_current_healthy":         3,
+		"kube_poddisruptionbudget_status_desired_healthy":         2,
+		"kube_poddisruptionbudget_status_expected_pods":           3,
+	}
+	mfs := gatherFrom(t, pc)
+	if len(mfs) != len(want) {
+		t.Fatalf("expected %d metric families, got %d", len(want), len(mfs))
+	}
+	for _, mf := range mfs {
+		m := mf.GetMetric()[0]
+		if v := m.GetGauge().GetValue(); v != want[mf.GetName()] || labelValue(m, "poddisruptionbudget") != "web" {
+			t.Errorf("%s: expected %v for web, got %v for %s", mf.GetName(), want[mf.GetName()], v, labelValue(m, "poddisruptionbudget"))
+		}
+	}
+}
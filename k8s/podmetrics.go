@@ -0,0 +1,55 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// podMetricsPath serves the series of a single pod at
+// podMetricsPath/{namespace}/{name}.
+const podMetricsPath = "/metrics/pod/"
+
+// podMetricsHandler serves the metrics pc emits for the single pod named by
+// the request path, looked up with lookup, or 404 if it is not cached.
+func podMetricsHandler(pc podCollector, lookup func(namespace, name string) (v1.Pod, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, podMetricsPath), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "expected "+podMetricsPath+"{namespace}/{name}", http.StatusBadRequest)
+			return
+		}
+		pod, ok := lookup(parts[0], parts[1])
+		if !ok {
+			http.Error(w, "pod "+parts[0]+"/"+parts[1]+" not found", http.StatusNotFound)
+			return
+		}
+
+		pc.store = PodLister(func() ([]v1.Pod, error) { return []v1.Pod{pod}, nil })
+		reg := prometheus.NewRegistry()
+		if err := reg.Register(&pc); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
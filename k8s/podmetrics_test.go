@@ -0,0 +1,36 @@
+package k8s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestPodMetricsHandler(t *testing.T) {
+	pods := map[string]v1.Pod{
+		"ns/web": {ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "web"}},
+		"ns/db":  {ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "db"}},
+	}
+	h := podMetricsHandler(podCollector{}, func(namespace, name string) (v1.Pod, bool) {
+		p, ok := pods[namespace+"/"+name]
+		return p, ok
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", podMetricsPath+"ns/web", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `pod="web"`) || strings.Contains(body, `pod="db"`) {
+		t.Errorf("expected only the series of ns/web, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", podMetricsPath+"ns/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing pod, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"k8s.io/client-go/pkg/util/wait"
+)
+
+// pushMetrics pushes everything gathered by gatherer to the Pushgateway at url
+// every interval until stopCh is closed, for environments that cannot scrape
+// the metrics server.
+func pushMetrics(url, job string, interval time.Duration, gatherer prometheus.Gatherer, stopCh <-chan struct{}) {
+	glog.Infof("Pushing metrics to %s every %s", url, interval)
+	wait.Until(func() {
+		if err := push.FromGatherer(job, nil, url, gatherer); err != nil {
+			glog.Errorf("pushing metrics to %s failed: %s", url, err)
+		}
+	}, interval, stopCh)
+}
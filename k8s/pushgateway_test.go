@@ -0,0 +1,35 @@
+package k8s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPushMetrics(t *testing.T) {
+	paths := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"}))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go pushMetrics(srv.URL, "kube-metrics", time.Hour, reg, stopCh)
+
+	select {
+	case p := <-paths:
+		if p != "/metrics/job/kube-metrics" {
+			t.Errorf("unexpected push path %s", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no push received")
+	}
+}
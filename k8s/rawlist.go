@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/discovery"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/util/wait"
+	restclient "k8s.io/client-go/rest"
+)
+
+// rawListCache keeps the items of a resource the vendored client-go cannot
+// watch, listed raw every period, so that scrapes read the cache instead of
+// listing the cluster. The served group version is looked up on each refresh
+// until one is found and again once it stops being served, so that APIs
+// enabled or migrated after startup are picked up.
+type rawListCache struct {
+	resource      string
+	groupVersions []string
+	discovery     discovery.ServerResourcesInterface
+	// list returns the decoded items of resource served in gv.
+	list func(gv string) (interface{}, error)
+
+	mu    sync.RWMutex
+	gv    string
+	items interface{}
+}
+
+// run refreshes the cache every period until stopCh is closed.
+func (c *rawListCache) run(period time.Duration, stopCh <-chan struct{}) {
+	wait.Until(c.refresh, period, stopCh)
+}
+
+func (c *rawListCache) refresh() {
+	c.mu.RLock()
+	gv := c.gv
+	c.mu.RUnlock()
+
+	if gv == "" {
+		var err error
+		if gv, err = servedGroupVersion(c.discovery, c.resource, c.groupVersions); err != nil {
+			c.set("", nil)
+			return
+		}
+		glog.Infof("collecting %s from %s", c.resource, gv)
+	}
+	items, err := c.list(gv)
+	if apierrors.IsNotFound(err) {
+		glog.Infof("%s no longer served in %s", c.resource, gv)
+		c.set("", nil)
+		return
+	}
+	if err != nil {
+		// Keep the last items, like an informer whose watch broke.
+		glog.Warningf("listing %s failed: %s", c.resource, err)
+		return
+	}
+	c.set(gv, items)
+}
+
+func (c *rawListCache) set(gv string, items interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gv, c.items = gv, items
+}
+
+// get returns the items of the last successful list, or nil while the
+// resource is not served.
+func (c *rawListCache) get() interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.items
+}
+
+// getRaw gets the path joined from segments with c and decodes the JSON
+// response into obj.
+func getRaw(c restclient.Interface, obj interface{}, segments ...string) error {
+	bs, err := c.Get().AbsPath(segments...).DoRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, obj)
+}
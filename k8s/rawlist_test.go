@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/client-go/discovery/fake"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/runtime/schema"
+	core "k8s.io/client-go/testing"
+)
+
+func TestRawListCache(t *testing.T) {
+	d := &fake.FakeDiscovery{Fake: &core.Fake{}}
+	var listErr error
+	lists := 0
+	c := &rawListCache{
+		resource:      "leases",
+		groupVersions: leaseGroupVersions,
+		discovery:     d,
+		list: func(gv string) (interface{}, error) {
+			lists++
+			if listErr != nil {
+				return nil, listErr
+			}
+			return []lease{{}}, nil
+		},
+	}
+
+	c.refresh()
+	if c.get() != nil || lists != 0 {
+		t.Fatalf("expected no list while leases are not served, got %v after %d lists", c.get(), lists)
+	}
+
+	d.Resources = map[string]*unversioned.APIResourceList{
+		"coordination.k8s.io/v1": {APIResources: []unversioned.APIResource{{Name: "leases"}}},
+	}
+	c.refresh()
+	if leases, _ := c.get().([]lease); len(leases) != 1 {
+		t.Fatalf("expected the leases once the API is served, got %v", c.get())
+	}
+
+	listErr = errors.New("connection refused")
+	c.refresh()
+	if leases, _ := c.get().([]lease); len(leases) != 1 {
+		t.Errorf("expected the last leases to be kept on a failed list, got %v", c.get())
+	}
+
+	listErr = apierrors.NewNotFound(schema.GroupResource{Resource: "leases"}, "")
+	c.refresh()
+	if c.get() != nil || c.gv != "" {
+		t.Errorf("expected the cache to be reset once the API is gone, got %v in %q", c.get(), c.gv)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	relabelReplace = "replace"
+	relabelKeep    = "keep"
+	relabelDrop    = "drop"
+)
+
+// relabelConfigs are the rules loaded from --relabel-config, applied to
+// every series served on metricsPath.
+var relabelConfigs []*relabelConfig
+
+// relabelConfig is a relabeling rule in the style of Prometheus. The values
+// of SourceLabels joined by Separator are matched against Regex. Series that
+// do not match are dropped by keep, the matching ones by drop, and replace
+// sets TargetLabel to Replacement with the groups of the match expanded.
+// The metric name is available as the __name__ source label.
+type relabelConfig struct {
+	SourceLabels []string `json:"source_labels"`
+	Separator    *string  `json:"separator"`
+	Regex        *string  `json:"regex"`
+	TargetLabel  string   `json:"target_label"`
+	Replacement  *string  `json:"replacement"`
+	Action       string   `json:"action"`
+
+	regex *regexp.Regexp
+}
+
+// loadRelabelConfigs reads a JSON array of relabeling rules from path,
+// filling in the defaults of Prometheus.
+func loadRelabelConfigs(path string) ([]*relabelConfig, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []*relabelConfig
+	if err := json.Unmarshal(bs, &cfgs); err != nil {
+		return nil, fmt.Errorf("parsing %s failed: %s", path, err)
+	}
+	for i, c := range cfgs {
+		if c.Separator == nil {
+			c.Separator = proto.String(";")
+		}
+		if c.Regex == nil {
+			c.Regex = proto.String("(.*)")
+		}
+		if c.Replacement == nil {
+			c.Replacement = proto.String("$1")
+		}
+		if c.Action == "" {
+			c.Action = relabelReplace
+		}
+		switch c.Action {
+		case relabelKeep, relabelDrop:
+		case relabelReplace:
+			if c.TargetLabel == "" || c.TargetLabel == "__name__" {
+				return nil, fmt.Errorf("rule %d: replace needs a target_label other than __name__", i)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, c.Action)
+		}
+		if c.regex, err = regexp.Compile("^(?:" + *c.Regex + ")$"); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i, err)
+		}
+	}
+	return cfgs, nil
+}
+
+// relabel applies cfgs to the labels of a series of the metric name. It
+// returns the new labels, or false if the series is dropped.
+func relabel(name string, labels []*dto.LabelPair, cfgs []*relabelConfig) ([]*dto.LabelPair, bool) {
+	ls := make(map[string]string, len(labels)+1)
+	for _, l := range labels {
+		ls[l.GetName()] = l.GetValue()
+	}
+	ls["__name__"] = name
+
+	for _, c := range cfgs {
+		values := make([]string, len(c.SourceLabels))
+		for i, l := range c.SourceLabels {
+			values[i] = ls[l]
+		}
+		value := strings.Join(values, *c.Separator)
+		switch c.Action {
+		case relabelKeep:
+			if !c.regex.MatchString(value) {
+				return nil, false
+			}
+		case relabelDrop:
+			if c.regex.MatchString(value) {
+				return nil, false
+			}
+		case relabelReplace:
+			match := c.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			if v := string(c.regex.ExpandString(nil, *c.Replacement, value, match)); v != "" {
+				ls[c.TargetLabel] = v
+			} else {
+				delete(ls, c.TargetLabel)
+			}
+		}
+	}
+
+	delete(ls, "__name__")
+	res := make([]*dto.LabelPair, 0, len(ls))
+	for n, v := range ls {
+		res = append(res, &dto.LabelPair{Name: proto.String(n), Value: proto.String(v)})
+	}
+	sort.Sort(prometheus.LabelPairSorter(res))
+	return res, true
+}
+
+// relabelGatherer gathers the series of gatherer relabeled by configs.
+// Families left without series are dropped.
+type relabelGatherer struct {
+	gatherer prometheus.Gatherer
+	configs  []*relabelConfig
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	res := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		var ms []*dto.Metric
+		for _, m := range mf.GetMetric() {
+			labels, ok := relabel(mf.GetName(), m.GetLabel(), g.configs)
+			if !ok {
+				continue
+			}
+			relabeled := *m
+			relabeled.Label = labels
+			ms = append(ms, &relabeled)
+		}
+		if len(ms) > 0 {
+			res = append(res, &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Metric: ms})
+		}
+	}
+	return res, err
+}
@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func writeRelabelConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "relabel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "relabel.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRelabelDropNamespace(t *testing.T) {
+	path := writeRelabelConfig(t, `[
+		{"source_labels": ["namespace"], "regex": "kube-.*", "action": "drop"},
+		{"source_labels": ["__name__", "pod"], "regex": "(.*);(.*)", "target_label": "series", "replacement": "$1/$2"}
+	]`)
+	defer os.RemoveAll(filepath.Dir(path))
+	cfgs, err := loadRelabelConfigs(path)
+	if err != nil {
+		t.Fatalf("loading relabel config failed: %s", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	gv := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_pods", Help: "Test gauge."}, []string{"namespace", "pod"})
+	reg.MustRegister(gv)
+	gv.WithLabelValues("kube-system", "dns").Set(1)
+	gv.WithLabelValues("default", "web").Set(2)
+
+	mfs, err := relabelGatherer{reg, cfgs}.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %s", err)
+	}
+	if len(mfs) != 1 || len(mfs[0].GetMetric()) != 1 {
+		t.Fatalf("expected a single series to be left, got %v", mfs)
+	}
+	m := mfs[0].GetMetric()[0]
+	if v := labelValue(m, "namespace"); v != "default" {
+		t.Errorf("expected series of namespace default to be kept, got %q", v)
+	}
+	if v := labelValue(m, "series"); v != "test_pods/web" {
+		t.Errorf("expected series label %q, got %q", "test_pods/web", v)
+	}
+}
+
+func TestRelabelConfigInvalid(t *testing.T) {
+	for _, content := range []string{
+		`[{"action": "hashmod"}]`,
+		`[{"source_labels": ["pod"], "target_label": "__name__"}]`,
+		`[{"source_labels": ["pod"], "regex": "(", "action": "keep"}]`,
+	} {
+		path := writeRelabelConfig(t, content)
+		if _, err := loadRelabelConfigs(path); err == nil {
+			t.Errorf("expected %s to be rejected", content)
+		}
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/util/wait"
+)
+
+// trackingRegisterer remembers the collectors successfully registered
+// through it, so that they can be unregistered again.
+type trackingRegisterer struct {
+	prometheus.Registerer
+
+	mu         sync.Mutex
+	registered []prometheus.Collector
+}
+
+func (r *trackingRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registered = append(r.registered, c)
+	return nil
+}
+
+func (r *trackingRegisterer) MustRegister(cs ...prometheus.Collector) {
+	r.Registerer.MustRegister(cs...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registered = append(r.registered, cs...)
+}
+
+// unregisterAll unregisters all collectors registered through r.
+func (r *trackingRegisterer) unregisterAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.registered {
+		r.Registerer.Unregister(c)
+	}
+	r.registered = nil
+}
+
+// collectionRestarter restarts the metric collection with a new client, e.g.
+// after the credentials in the kubeconfig were rotated.
+type collectionRestarter struct {
+	newClient func() (clientset.Interface, error)
+	start     func(clientset.Interface, <-chan struct{}) *MetricCollection
+
+	mu         sync.Mutex
+	stop       chan struct{}
+	collection *MetricCollection
+}
+
+// restart stops the running collection, if any, and starts one for
+// kubeClient.
+func (r *collectionRestarter) restart(kubeClient clientset.Interface) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.collection.reg.unregisterAll()
+	}
+	r.stop = make(chan struct{})
+	r.collection = r.start(kubeClient, r.stop)
+}
+
+// reload rebuilds the client and restarts the collection with it. The
+// running collection is kept if the client cannot be built.
+func (r *collectionRestarter) reload() {
+	glog.Infof("Rebuilding the kube client")
+	kubeClient, err := r.newClient()
+	if err != nil {
+		glog.Errorf("rebuilding the kube client failed, keeping the old one: %s", err)
+		return
+	}
+	r.restart(kubeClient)
+}
+
+// watchFile calls onChange whenever the modification time or size of the
+// file at path changed, checking every interval until stopCh is closed. It
+// polls rather than using inotify, which misses the symlink swaps of
+// mounted secrets.
+func watchFile(path string, interval time.Duration, onChange func(), stopCh <-chan struct{}) {
+	stat := func() (time.Time, int64) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			glog.Warningf("checking %s for changes failed: %s", path, err)
+			return time.Time{}, -1
+		}
+		return fi.ModTime(), fi.Size()
+	}
+	lastMod, lastSize := stat()
+	wait.Until(func() {
+		mod, size := stat()
+		if size < 0 || (mod.Equal(lastMod) && size == lastSize) {
+			return
+		}
+		lastMod, lastSize = mod, size
+		onChange()
+	}, interval, stopCh)
+}
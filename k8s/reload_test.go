@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchFileTriggersClientRebuild(t *testing.T) {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("token: old")
+	f.Close()
+
+	clients := make(chan clientset.Interface, 10)
+	var stops []<-chan struct{}
+	r := &collectionRestarter{
+		newClient: func() (clientset.Interface, error) { return fake.NewSimpleClientset(), nil },
+		start: func(c clientset.Interface, stopCh <-chan struct{}) *MetricCollection {
+			stops = append(stops, stopCh)
+			clients <- c
+			return &MetricCollection{reg: &trackingRegisterer{}}
+		},
+	}
+	r.restart(fake.NewSimpleClientset())
+	<-clients
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go watchFile(f.Name(), 10*time.Millisecond, r.reload, stopCh)
+
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(f.Name(), []byte("token: rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-clients:
+	case <-time.After(time.Second):
+		t.Fatal("modifying the kubeconfig did not rebuild the client")
+	}
+
+	select {
+	case <-stops[0]:
+	default:
+		t.Error("the previous collection was not stopped")
+	}
+}
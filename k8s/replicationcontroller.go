@@ -23,25 +23,45 @@ import (
 )
 
 var (
-	dsecReplicationControllerStatusReplicas = prometheus.NewDesc(
+	descReplicationControllerSpecReplicas = newObjectDesc(
+		"kube_replication_controller_spec_replicas",
+		"The number of desired replicas per replication controller.",
+		[]string{"namespace", "replicationcontroller"},
+	)
+	descReplicationControllerReplicaDrift = newObjectDesc(
+		"kube_replication_controller_replica_drift",
+		"The number of desired replicas that are not ready per replication controller.",
+		[]string{"namespace", "replicationcontroller"},
+	)
+	dsecReplicationControllerStatusReplicas = newObjectDesc(
 		"kube_replication_controller_status_replicas",
 		"The number of replicas per deployment.",
-		[]string{"namespace", "replicationcontroller"}, nil,
+		[]string{"namespace", "replicationcontroller"},
 	)
-	descReplicationControllerStatusReplicasAvailable = prometheus.NewDesc(
+	descReplicationControllerStatusReplicasAvailable = newObjectDesc(
 		"kube_replication_controller_status_replicas_available",
 		"The number of available replicas per deployment.",
-		[]string{"namespace", "replicationcontroller"}, nil,
+		[]string{"namespace", "replicationcontroller"},
+	)
+	descReplicationControllerStatusAvailableReplicas = newObjectDesc(
+		"kube_replication_controller_status_available_replicas",
+		"The number of replicas per replication controller ready for at least minReadySeconds; kube_replication_controller_status_replicas_available reports the ready ones.",
+		[]string{"namespace", "replicationcontroller"},
+	)
+	descReplicationControllerStatusReplicasReady = newObjectDesc(
+		"kube_replication_controller_status_replicas_ready",
+		"The number of ready replicas per replication controller.",
+		[]string{"namespace", "replicationcontroller"},
 	)
-	descReplicationControllerStatusReplicasUnavailable = prometheus.NewDesc(
+	descReplicationControllerStatusReplicasUnavailable = newObjectDesc(
 		"kube_replication_controller_status_replicas_unavailable",
 		"The number of unavailable replicas per deployment.",
-		[]string{"namespace", "replicationcontroller"}, nil,
+		[]string{"namespace", "replicationcontroller"},
 	)
-	descReplicationControllerStatusReplicasUpdated = prometheus.NewDesc(
+	descReplicationControllerStatusReplicasUpdated = newObjectDesc(
 		"kube_replication_controller_status_replicas_updated",
 		"The number of updated replicas per deployment.",
-		[]string{"namespace", "replicationcontroller"}, nil,
+		[]string{"namespace", "replicationcontroller"},
 	)
 )
 
@@ -51,13 +71,18 @@ type replicationcontrollerStore interface {
 
 // deploymentCollector collects metrics about all deployments in the cluster.
 type replicationcontrollerCollector struct {
-	store replicationcontrollerStore
+	store      replicationcontrollerStore
+	namespaces namespaceFilter
 }
 
 // Describe implements the prometheus.Collector interface.
 func (rcc *replicationcontrollerCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- dsecReplicationControllerStatusReplicas
-	ch <- descReplicationControllerStatusReplicasAvailable
+	ch <- objectDesc(descReplicationControllerSpecReplicas)
+	ch <- objectDesc(descReplicationControllerReplicaDrift)
+	ch <- objectDesc(dsecReplicationControllerStatusReplicas)
+	ch <- objectDesc(descReplicationControllerStatusReplicasAvailable)
+	ch <- objectDesc(descReplicationControllerStatusAvailableReplicas)
+	ch <- objectDesc(descReplicationControllerStatusReplicasReady)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -68,6 +93,9 @@ func (rcc *replicationcontrollerCollector) Collect(ch chan<- prometheus.Metric)
 		return
 	}
 	for _, r := range rcs {
+		if !rcc.namespaces.allowed(r.Namespace) {
+			continue
+		}
 		rcc.collectReplicaontController(ch, r)
 	}
 }
@@ -75,8 +103,15 @@ func (rcc *replicationcontrollerCollector) Collect(ch chan<- prometheus.Metric)
 func (rcc *replicationcontrollerCollector) collectReplicaontController(ch chan<- prometheus.Metric, rc v1.ReplicationController) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
 		lv = append([]string{rc.Namespace, rc.Name}, lv...)
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+		ch <- mustNewObjectMetric(desc, rc.UID, prometheus.GaugeValue, v, lv...)
 	}
+	desired := replicas(rc.Spec.Replicas)
+	addGauge(descReplicationControllerSpecReplicas, float64(desired))
+	addGauge(descReplicationControllerReplicaDrift, float64(desired-rc.Status.ReadyReplicas))
 	addGauge(dsecReplicationControllerStatusReplicas, float64(rc.Status.Replicas))
+	// The series predates AvailableReplicas and has always reported the
+	// ready replicas; dashboards rely on that.
 	addGauge(descReplicationControllerStatusReplicasAvailable, float64(rc.Status.ReadyReplicas))
+	addGauge(descReplicationControllerStatusAvailableReplicas, float64(rc.Status.AvailableReplicas))
+	addGauge(descReplicationControllerStatusReplicasReady, float64(rc.Status.ReadyReplicas))
 }
@@ -0,0 +1,36 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestReplicationControllerReplicaDrift(t *testing.T) {
+	desired := int32(5)
+	rcs := []v1.ReplicationController{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "web"},
+		Spec:       v1.ReplicationControllerSpec{Replicas: &desired},
+		Status:     v1.ReplicationControllerStatus{Replicas: 5, ReadyReplicas: 3, AvailableReplicas: 2},
+	}}
+	rcc := &replicationcontrollerCollector{store: RCLister(func() ([]v1.ReplicationController, error) { return rcs, nil })}
+
+	got := map[string]float64{}
+	for _, mf := range gatherFrom(t, rcc) {
+		for _, m := range mf.GetMetric() {
+			got[mf.GetName()] = m.GetGauge().GetValue()
+		}
+	}
+	for name, want := range map[string]float64{
+		"kube_replication_controller_spec_replicas":             5,
+		"kube_replication_controller_replica_drift":             2,
+		"kube_replication_controller_status_replicas":           5,
+		"kube_replication_controller_status_replicas_ready":     3,
+		"kube_replication_controller_status_replicas_available": 3,
+		"kube_replication_controller_status_available_replicas": 2,
+	} {
+		if v, ok := got[name]; !ok || v != want {
+			t.Errorf("%s: expected %v, got %v", name, want, v)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// persistedVersions are the resource versions of --resource-version-file, or
+// nil if it is not set.
+var persistedVersions *resourceVersions
+
+// resourceVersions are the last resource versions synced per resource,
+// persisted to a file so that a restarted agent can resume from them.
+type resourceVersions struct {
+	path string
+
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// loadResourceVersions reads the resource versions persisted at path. A
+// missing or unreadable file starts out empty.
+func loadResourceVersions(path string) *resourceVersions {
+	rvs := &resourceVersions{path: path, versions: map[string]string{}}
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("reading resource versions from %s failed: %s", path, err)
+		}
+		return rvs
+	}
+	if err := json.Unmarshal(bs, &rvs.versions); err != nil {
+		glog.Warningf("parsing resource versions from %s failed: %s", path, err)
+		rvs.versions = map[string]string{}
+	}
+	return rvs
+}
+
+func (rvs *resourceVersions) get(resource string) string {
+	rvs.mu.Lock()
+	defer rvs.mu.Unlock()
+	return rvs.versions[resource]
+}
+
+func (rvs *resourceVersions) set(resource, version string) {
+	if version == "" {
+		return
+	}
+	rvs.mu.Lock()
+	defer rvs.mu.Unlock()
+	rvs.versions[resource] = version
+}
+
+// save writes the resource versions next to the file and renames it into
+// place, so that a crash never leaves a partial file behind.
+func (rvs *resourceVersions) save() error {
+	rvs.mu.Lock()
+	bs, err := json.Marshal(rvs.versions)
+	rvs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(rvs.path), filepath.Base(rvs.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(bs); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), rvs.path)
+}
+
+// saveResourceVersions persists the resource versions last synced by the
+// informers that have synced.
+func saveResourceVersions(rvs *resourceVersions, informers map[string]cache.SharedInformer) {
+	for resource, inf := range informers {
+		if inf.HasSynced() {
+			rvs.set(resource, inf.LastSyncResourceVersion())
+		}
+	}
+	if err := rvs.save(); err != nil {
+		glog.Errorf("saving resource versions to %s failed: %s", rvs.path, err)
+	}
+}
+
+// resumeListWatch returns lw listing resource at its persisted resource
+// version the first time, so that the apiserver does not need a quorum read
+// of the latest state and the watch resumes from where the last run of the
+// agent left off. If the version is too old for the apiserver, usually 410
+// Gone, the list is repeated without it.
+func resumeListWatch(resource string, lw *cache.ListWatch, rvs *resourceVersions) *cache.ListWatch {
+	if rvs == nil {
+		return lw
+	}
+	var once sync.Once
+	return &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			version := ""
+			once.Do(func() { version = rvs.get(resource) })
+			if version != "" {
+				resumed := options
+				resumed.ResourceVersion = version
+				list, err := lw.ListFunc(resumed)
+				if err == nil {
+					glog.Infof("resumed %s at resource version %s", resource, version)
+					return list, nil
+				}
+				glog.Infof("resuming %s at resource version %s failed, listing it afresh: %s", resource, version, err)
+			}
+			return lw.ListFunc(options)
+		},
+		WatchFunc: lw.WatchFunc,
+	}
+}
@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResumeListWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "versions.json")
+
+	saved := loadResourceVersions(path)
+	saved.set("pods", "123")
+	if err := saved.save(); err != nil {
+		t.Fatalf("saving resource versions failed: %s", err)
+	}
+
+	var listed []string
+	gone := false
+	lw := &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			listed = append(listed, options.ResourceVersion)
+			if gone && options.ResourceVersion == "123" {
+				return nil, errors.New("410 Gone")
+			}
+			return &v1.PodList{}, nil
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+
+	rlw := resumeListWatch("pods", lw, loadResourceVersions(path))
+	rlw.List(v1.ListOptions{ResourceVersion: "0"})
+	rlw.List(v1.ListOptions{ResourceVersion: "0"})
+	if len(listed) != 2 || listed[0] != "123" || listed[1] != "0" {
+		t.Errorf("expected the persisted version for the first list only, got %v", listed)
+	}
+
+	listed, gone = nil, true
+	rlw = resumeListWatch("pods", lw, loadResourceVersions(path))
+	if _, err := rlw.List(v1.ListOptions{ResourceVersion: "0"}); err != nil {
+		t.Fatalf("expected the list to fall back, got %s", err)
+	}
+	if len(listed) != 2 || listed[1] != "0" {
+		t.Errorf("expected a fresh list after the persisted version expired, got %v", listed)
+	}
+}
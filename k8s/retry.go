@@ -0,0 +1,72 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// retryInitialList returns lw retrying its first list of resource up to
+// retries times on errors, doubling the wait from backoff after each
+// attempt. Later lists are left to the retries of the reflector.
+func retryInitialList(resource string, lw *cache.ListWatch, retries int, backoff time.Duration) *cache.ListWatch {
+	if retries <= 0 {
+		return lw
+	}
+	var (
+		mu     sync.Mutex
+		listed bool
+	)
+	return &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			mu.Lock()
+			initial := !listed
+			mu.Unlock()
+			if !initial {
+				return lw.ListFunc(options)
+			}
+
+			wait := backoff
+			for attempt := 1; ; attempt++ {
+				list, err := lw.ListFunc(options)
+				if err == nil {
+					if attempt > 1 {
+						glog.Infof("initial list of %s succeeded on attempt %d", resource, attempt)
+					}
+					mu.Lock()
+					listed = true
+					mu.Unlock()
+					return list, nil
+				}
+				if attempt > retries {
+					glog.Errorf("initial list of %s failed after %d attempts: %s", resource, attempt, err)
+					return list, err
+				}
+				glog.Warningf("initial list of %s failed on attempt %d of %d, retrying in %s: %s", resource, attempt, retries+1, wait, err)
+				time.Sleep(wait)
+				wait *= 2
+			}
+		},
+		WatchFunc: lw.WatchFunc,
+	}
+}
@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRetryInitialList(t *testing.T) {
+	calls := 0
+	failures := 1
+	lw := retryInitialList("pods", &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			calls++
+			if calls <= failures {
+				return nil, errors.New("connection refused")
+			}
+			return &v1.PodList{}, nil
+		},
+	}, 3, time.Millisecond)
+
+	if _, err := lw.List(v1.ListOptions{}); err != nil {
+		t.Fatalf("expected the initial list to succeed after a retry, got %s", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+
+	// Later lists are not retried.
+	calls, failures = 0, 1
+	if _, err := lw.List(v1.ListOptions{}); err == nil || calls != 1 {
+		t.Errorf("expected a later failure to be returned at once, got %v after %d attempts", err, calls)
+	}
+}
+
+func TestRetryInitialListGivesUp(t *testing.T) {
+	calls := 0
+	lw := retryInitialList("pods", &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			calls++
+			return nil, errors.New("connection refused")
+		},
+	}, 2, time.Millisecond)
+
+	if _, err := lw.List(v1.ListOptions{}); err == nil {
+		t.Fatal("expected the error after the retries")
+	}
+	if calls != 3 {
+		t.Errorf("expected the first attempt and 2 retries, got %d attempts", calls)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var (
+	descServiceAccountInfo = newObjectDesc(
+		"kube_serviceaccount_info",
+		"Information about a service account.",
+		[]string{"namespace", "serviceaccount"},
+	)
+	descServiceAccountSecrets = newObjectDesc(
+		"kube_serviceaccount_secrets",
+		"The number of secrets referenced by a service account.",
+		[]string{"namespace", "serviceaccount"},
+	)
+)
+
+type serviceaccountStore interface {
+	List() ([]v1.ServiceAccount, error)
+}
+
+// serviceaccountCollector collects metrics about all service accounts in the
+// cluster for security audits. It counts the referenced secrets but never
+// reads them.
+type serviceaccountCollector struct {
+	store      serviceaccountStore
+	namespaces namespaceFilter
+}
+
+// Describe implements the prometheus.Collector interface.
+func (sc *serviceaccountCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectDesc(descServiceAccountInfo)
+	ch <- objectDesc(descServiceAccountSecrets)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (sc *serviceaccountCollector) Collect(ch chan<- prometheus.Metric) {
+	sas, err := sc.store.List()
+	if err != nil {
+		glog.Errorf("listing service accounts failed: %s", err)
+		return
+	}
+	for _, sa := range sas {
+		if !sc.namespaces.allowed(sa.Namespace) {
+			continue
+		}
+		sc.collectServiceAccount(ch, sa)
+	}
+}
+
+func (sc *serviceaccountCollector) collectServiceAccount(ch chan<- prometheus.Metric, sa v1.ServiceAccount) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		lv = append([]string{sa.Namespace, sa.Name}, lv...)
+		ch <- mustNewObjectMetric(desc, sa.UID, prometheus.GaugeValue, v, lv...)
+	}
+	addGauge(descServiceAccountInfo, 1)
+	addGauge(descServiceAccountSecrets, float64(len(sa.Secrets)))
+}
@@ -0,0 +1,28 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestServiceAccountCollector(t *testing.T) {
+	sas := []v1.ServiceAccount{{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "builder"},
+		Secrets:    []v1.ObjectReference{{Name: "builder-token"}, {Name: "registry"}},
+	}}
+	sc := &serviceaccountCollector{store: ServiceAccountLister(func() ([]v1.ServiceAccount, error) { return sas, nil })}
+
+	got := map[string]float64{}
+	for _, mf := range gatherFrom(t, sc) {
+		for _, m := range mf.GetMetric() {
+			if sa := labelValue(m, "serviceaccount"); sa != "builder" {
+				t.Errorf("%s: expected serviceaccount builder, got %q", mf.GetName(), sa)
+			}
+			got[mf.GetName()] = m.GetGauge().GetValue()
+		}
+	}
+	if got["kube_serviceaccount_info"] != 1 || got["kube_serviceaccount_secrets"] != 2 {
+		t.Errorf("expected info 1 and 2 secrets, got %v", got)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/client-go/pkg/util/wait"
+)
+
+// exportMetricsFile writes everything gathered by gatherer to path in the
+// Prometheus text format every interval until stopCh is closed, for
+// air-gapped environments where another process ships the file.
+func exportMetricsFile(path string, interval time.Duration, gatherer prometheus.Gatherer, stopCh <-chan struct{}) {
+	glog.Infof("Writing metrics to %s every %s", path, interval)
+	wait.Until(func() {
+		if err := writeMetricsFile(path, gatherer); err != nil {
+			glog.Errorf("writing metrics to %s failed: %s", path, err)
+		}
+	}, interval, stopCh)
+}
+
+// writeMetricsFile writes the metrics gathered by gatherer to path. The file
+// is written next to path and renamed into place, so readers never see a
+// partial file.
+func writeMetricsFile(path string, gatherer prometheus.Gatherer) error {
+	mfs, err := gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		return err
+	}
+	if err != nil {
+		glog.Errorf("gathering metrics for %s: %s", path, err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(f, mf); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestWriteMetricsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "textfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"})
+	reg.MustRegister(g)
+	g.Set(42)
+
+	path := filepath.Join(dir, "metrics.prom")
+	if err := writeMetricsFile(path, reg); err != nil {
+		t.Fatalf("writing metrics file failed: %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var parser expfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		t.Fatalf("parsing metrics file failed: %s", err)
+	}
+	if v := mfs["test_gauge"].GetMetric()[0].GetGauge().GetValue(); v != 42 {
+		t.Errorf("expected test_gauge 42, got %v", v)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the metrics file to be left, got %d files", len(files))
+	}
+}
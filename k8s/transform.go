@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"k8s.io/client-go/pkg/api/meta"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// neededAnnotations are the annotations read by the collectors; all others
+// are dropped from cached objects when --strip-annotations is set.
+var neededAnnotations = map[string]bool{
+	v1.TolerationsAnnotationKey:           true,
+	v1.TaintsAnnotationKey:                true,
+	v1.PodInitContainersAnnotationKey:     true,
+	v1.PodInitContainersBetaAnnotationKey: true,
+}
+
+// stripObject drops what the collectors never read from obj before it is
+// cached, as annotations can be large on big clusters. Fields unknown to the
+// vendored API types, such as managedFields, are never decoded to begin with.
+func stripObject(obj runtime.Object) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	annotations := m.GetAnnotations()
+	for k := range annotations {
+		if !neededAnnotations[k] {
+			delete(annotations, k)
+		}
+	}
+	if len(annotations) == 0 {
+		m.SetAnnotations(nil)
+	}
+}
+
+// cacheTransform returns the transform applied to objects before they are
+// cached: repeated strings are always interned, annotations are stripped if
+// strip is set.
+func cacheTransform(strip bool) func(runtime.Object) {
+	if !strip {
+		return internObject
+	}
+	return func(obj runtime.Object) {
+		stripObject(obj)
+		internObject(obj)
+	}
+}
+
+// transformListWatch applies transform to every object lw lists or watches,
+// so that the informer only ever stores transformed objects.
+func transformListWatch(lw *cache.ListWatch, transform func(runtime.Object)) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			list, err := lw.ListFunc(options)
+			if err != nil {
+				return list, err
+			}
+			err = meta.EachListItem(list, func(obj runtime.Object) error {
+				transform(obj)
+				return nil
+			})
+			return list, err
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			w, err := lw.WatchFunc(options)
+			if err != nil {
+				return w, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				if in.Type != watch.Error {
+					transform(in.Object)
+				}
+				return in, true
+			}), nil
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestTransformStripsCachedObjects(t *testing.T) {
+	annotated := func(name string) v1.Pod {
+		return v1.Pod{ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      name,
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"huge":"blob"}`,
+				v1.TolerationsAnnotationKey:                        `[]`,
+			},
+		}}
+	}
+	fw := watch.NewFake()
+	lw := transformListWatch(&cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			return &v1.PodList{Items: []v1.Pod{annotated("listed")}}, nil
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			return fw, nil
+		},
+	}, stripObject)
+
+	check := func(p *v1.Pod) {
+		if _, ok := p.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+			t.Errorf("%s: unneeded annotation was kept", p.Name)
+		}
+		if _, ok := p.Annotations[v1.TolerationsAnnotationKey]; !ok {
+			t.Errorf("%s: annotation read by the collectors was dropped", p.Name)
+		}
+	}
+
+	list, err := lw.List(v1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(&list.(*v1.PodList).Items[0])
+
+	w, err := lw.Watch(v1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := annotated("watched")
+	go fw.Add(&watched)
+	check((<-w.ResultChan()).Object.(*v1.Pod))
+	w.Stop()
+}
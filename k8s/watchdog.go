@@ -0,0 +1,156 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// syncPollPeriod is how often the watchdog checks whether an informer synced.
+const syncPollPeriod = 100 * time.Millisecond
+
+func newInformerRebuilds() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_informer_rebuilds_total",
+			Help: "The number of informers rebuilt because they did not sync within the sync deadline, per resource.",
+		},
+		[]string{"resource"},
+	)
+}
+
+// watchedInformer is a cache.SharedInformer that is rebuilt by newInformer
+// whenever it does not sync within deadline, e.g. after a watch failed
+// silently. Event handlers are added again to every rebuilt informer.
+type watchedInformer struct {
+	resource    string
+	deadline    time.Duration
+	newInformer func() cache.SharedInformer
+	// rebuilds counts the rebuilds per resource.
+	rebuilds *prometheus.CounterVec
+
+	mu       sync.RWMutex
+	informer cache.SharedInformer
+	handlers []cache.ResourceEventHandler
+}
+
+// newWatchedInformer returns the informer built by newInformer, watched for
+// syncing within deadline and counting its rebuilds in rebuilds. A zero
+// deadline disables the watchdog.
+func newWatchedInformer(resource string, deadline time.Duration, rebuilds *prometheus.CounterVec, newInformer func() cache.SharedInformer) cache.SharedInformer {
+	if deadline <= 0 {
+		return newInformer()
+	}
+	return &watchedInformer{
+		resource:    resource,
+		deadline:    deadline,
+		newInformer: newInformer,
+		rebuilds:    rebuilds,
+		informer:    newInformer(),
+	}
+}
+
+func (w *watchedInformer) current() cache.SharedInformer {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.informer
+}
+
+func (w *watchedInformer) AddEventHandler(handler cache.ResourceEventHandler) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.informer.AddEventHandler(handler); err != nil {
+		return err
+	}
+	w.handlers = append(w.handlers, handler)
+	return nil
+}
+
+func (w *watchedInformer) GetStore() cache.Store {
+	return w.current().GetStore()
+}
+
+func (w *watchedInformer) GetController() cache.ControllerInterface {
+	return w.current().GetController()
+}
+
+func (w *watchedInformer) HasSynced() bool {
+	return w.current().HasSynced()
+}
+
+func (w *watchedInformer) LastSyncResourceVersion() string {
+	return w.current().LastSyncResourceVersion()
+}
+
+// Run runs the informer until stopCh is closed, replacing it with a new one
+// each time it does not sync within the deadline.
+func (w *watchedInformer) Run(stopCh <-chan struct{}) {
+	for {
+		inf := w.current()
+		runStop := make(chan struct{})
+		go inf.Run(runStop)
+		if w.synced(inf, stopCh) {
+			<-stopCh
+			close(runStop)
+			return
+		}
+		close(runStop)
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		glog.Warningf("%s informer did not sync within %s, rebuilding it", w.resource, w.deadline)
+		w.rebuilds.WithLabelValues(w.resource).Inc()
+		w.rebuild()
+	}
+}
+
+// synced waits for inf to sync and reports whether it did so before the
+// deadline and before stopCh was closed.
+func (w *watchedInformer) synced(inf cache.SharedInformer, stopCh <-chan struct{}) bool {
+	deadline := time.After(w.deadline)
+	tick := time.NewTicker(syncPollPeriod)
+	defer tick.Stop()
+	for !inf.HasSynced() {
+		select {
+		case <-stopCh:
+			return false
+		case <-deadline:
+			return false
+		case <-tick.C:
+		}
+	}
+	return true
+}
+
+func (w *watchedInformer) rebuild() {
+	inf := w.newInformer()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, h := range w.handlers {
+		if err := inf.AddEventHandler(h); err != nil {
+			glog.Errorf("adding event handler to rebuilt %s informer failed: %s", w.resource, err)
+		}
+	}
+	w.informer = inf
+}
@@ -0,0 +1,76 @@
+package k8s
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// stuckInformer never syncs, like an informer whose watch failed silently.
+type stuckInformer struct {
+	cache.SharedInformer
+
+	mu       sync.Mutex
+	handlers int
+	stopped  chan struct{}
+}
+
+func (s *stuckInformer) AddEventHandler(cache.ResourceEventHandler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers++
+	return nil
+}
+
+func (s *stuckInformer) HasSynced() bool { return false }
+
+func (s *stuckInformer) Run(stopCh <-chan struct{}) {
+	<-stopCh
+	close(s.stopped)
+}
+
+func TestWatchedInformerRebuildsStuckInformer(t *testing.T) {
+	built := make(chan *stuckInformer, 10)
+	rebuilds := newInformerRebuilds()
+	inf := newWatchedInformer("pods", 50*time.Millisecond, rebuilds, func() cache.SharedInformer {
+		s := &stuckInformer{stopped: make(chan struct{})}
+		built <- s
+		return s
+	})
+	first := <-built
+	inf.AddEventHandler(cache.ResourceEventHandlerFuncs{})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go inf.Run(stopCh)
+
+	var second *stuckInformer
+	select {
+	case second = <-built:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stuck informer to be rebuilt")
+	}
+	select {
+	case <-first.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stuck informer to be stopped")
+	}
+	second.mu.Lock()
+	handlers := second.handlers
+	second.mu.Unlock()
+	if handlers != 1 {
+		t.Errorf("expected the event handler to be added to the rebuilt informer, got %d handlers", handlers)
+	}
+	if v := counterValue(t, rebuilds, "pods"); v != 1 {
+		t.Errorf("expected agent_informer_rebuilds_total of 1, got %v", v)
+	}
+}
+
+func TestWatchedInformerDisabled(t *testing.T) {
+	s := &stuckInformer{}
+	if inf := newWatchedInformer("pods", 0, nil, func() cache.SharedInformer { return s }); inf != cache.SharedInformer(s) {
+		t.Errorf("expected the informer to be returned unwatched without a deadline")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/domeos/agent/cron"
 	"github.com/domeos/agent/funcs"
@@ -39,6 +41,8 @@ func main() {
 	g.InitRootDir()
 	g.InitLocalIp()
 	g.InitRpcClients()
+	g.InitTransferDiscovery()
+	g.InitSinks()
 
 	//if g.Config().Apiserver == "" {
 	//	os.Exit(0)
@@ -73,7 +77,19 @@ func main() {
 	cron.SyncTrustableIps()
 	cron.Collect()
 
+	stop := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		cron.SendAliveHeartbeat(stop)
+		close(heartbeatDone)
+	}()
+
 	go agenthttp.Start()
 
-	select {}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	close(stop)
+	agenthttp.DrainPushQueue()
+	<-heartbeatDone
 }